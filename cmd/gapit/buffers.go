@@ -0,0 +1,159 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+	"github.com/google/gapid/gapis/service"
+)
+
+type buffersVerb BuffersFlags
+
+func init() {
+	verb := &buffersVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "buffers",
+		ShortHelp: "Prints the buffers of a capture file and the memory backing them",
+		Action:    verb,
+	})
+}
+
+func (verb *buffersVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	client, capture, err := getGapisAndLoadCapture(ctx, verb.Gapis, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	numCommands, err := getNumCommands(ctx, client, capture)
+	if err != nil {
+		return err
+	}
+	if len(verb.At) == 0 {
+		verb.At = []uint64{numCommands - 1}
+	}
+	if err := checkCommandIndex(ctx, verb.At, numCommands); err != nil {
+		return err
+	}
+
+	mem, err := breakdown.Fetch(ctx, client, capture.Command(verb.At[0], verb.At[1:]...))
+	if err != nil {
+		return err
+	}
+	allocationFlags, err := breakdown.FetchAllocationFlags(ctx, client, mem)
+	if err != nil {
+		return err
+	}
+
+	printBuffers(collectBuffers(mem), allocationFlags)
+	return nil
+}
+
+// bufferInfo is a buffer resource and the memory bindings backing it.
+type bufferInfo struct {
+	handle  uint64
+	name    string
+	backing []bufferBacking
+}
+
+// bufferBacking is a single binding of memory to a buffer.
+type bufferBacking struct {
+	allocation *api.MemoryAllocation
+	binding    *api.MemoryBinding
+}
+
+// boundSize returns the total size of the memory bound to the buffer.
+func (b *bufferInfo) boundSize() uint64 {
+	size := uint64(0)
+	for _, backing := range b.backing {
+		size += backing.binding.Size
+	}
+	return size
+}
+
+// collectBuffers inverts the memory breakdown, returning the buffers bound to
+// the allocations sorted by handle. The buffers' backing bindings are sorted
+// by allocation, then offset.
+func collectBuffers(mem *api.MemoryBreakdown) []*bufferInfo {
+	buffers := map[uint64]*bufferInfo{}
+	for _, alloc := range mem.Allocations {
+		for _, binding := range alloc.Bindings {
+			switch binding.Type.(type) {
+			case *api.MemoryBinding_Buffer, *api.MemoryBinding_SparseBufferBlock:
+			default:
+				continue
+			}
+			buf, ok := buffers[binding.Handle]
+			if !ok {
+				buf = &bufferInfo{handle: binding.Handle, name: binding.Name}
+				buffers[binding.Handle] = buf
+			}
+			buf.backing = append(buf.backing, bufferBacking{alloc, binding})
+		}
+	}
+
+	out := make([]*bufferInfo, 0, len(buffers))
+	for _, buf := range buffers {
+		sort.Slice(buf.backing, func(i, j int) bool {
+			a, b := buf.backing[i], buf.backing[j]
+			if a.allocation.Handle != b.allocation.Handle {
+				return a.allocation.Handle < b.allocation.Handle
+			}
+			return a.binding.Offset < b.binding.Offset
+		})
+		out = append(out, buf)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].handle < out[j].handle })
+	return out
+}
+
+// printBuffers prints each buffer, followed by the bindings backing it. Buffer
+// usage flags are not part of the memory breakdown, so the property flags of
+// the backing allocations' memory types are printed instead.
+func printBuffers(buffers []*bufferInfo, allocationFlags *service.ConstantSet) {
+	w := tabwriter.NewWriter(os.Stdout, 4, 4, 0, ' ', 0)
+	fmt.Fprintf(w, "%v buffers\n", len(buffers))
+	flagNames := breakdown.NewFlagNameCache(allocationFlags)
+	for _, buf := range buffers {
+		fmt.Fprintln(w, "Name:", buf.name)
+		fmt.Fprintf(w, "\tHandle: \t0x%x\n", buf.handle)
+		fmt.Fprintf(w, "\tBound Size: \t%v\n", humanBytes(buf.boundSize()))
+		fmt.Fprintf(w, "\t%v backing bindings:\n", len(buf.backing))
+		for _, backing := range buf.backing {
+			fmt.Fprintf(w, "\t%v: %v\n", breakdown.BindingTypeName(backing.binding), backing.allocation.Name)
+			fmt.Fprintf(w, "\t\tMemory Offset: \t%v\n", backing.binding.Offset)
+			fmt.Fprintf(w, "\t\tSize: \t%v\n", humanBytes(backing.binding.Size))
+			if sparse, ok := backing.binding.Type.(*api.MemoryBinding_SparseBufferBlock); ok {
+				fmt.Fprintf(w, "\t\tBuffer Offset: \t%v\n", sparse.SparseBufferBlock.Offset)
+			}
+			fmt.Fprintf(w, "\t\tMemory Type: \t%v\n", memoryTypeName(backing.allocation, flagNames))
+		}
+	}
+	w.Flush()
+}
@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -62,7 +63,46 @@ func (c clientCloser) Close() error {
 	return c.Client.Close()
 }
 
+// The environment variables providing the defaults of the GAPIS connection
+// flags, for scripted and containerized setups.
+const (
+	gapisHostEnv  = "GAPID_GAPIS_HOST"
+	gapisPortEnv  = "GAPID_GAPIS_PORT"
+	gapisTokenEnv = "GAPID_GAPIS_TOKEN"
+)
+
+// gapisFlagsFromEnv returns the GAPIS flags with the host, port and token that
+// were not set on the command line taken from the environment. The token is
+// only taken when connecting to an existing server, as a server started by
+// gapit gets a new token.
+func gapisFlagsFromEnv(gapisFlags GapisFlags, getenv func(string) string) (GapisFlags, error) {
+	if gapisFlags.Host == "" {
+		gapisFlags.Host = getenv(gapisHostEnv)
+	}
+	if gapisFlags.Port == 0 {
+		if port := getenv(gapisPortEnv); port != "" {
+			p, err := strconv.Atoi(port)
+			if err != nil || p <= 0 {
+				return gapisFlags, fmt.Errorf("Invalid %v %q", gapisPortEnv, port)
+			}
+			gapisFlags.Port = p
+		}
+	}
+	if gapisFlags.Token == "" && gapisFlags.Port != 0 {
+		gapisFlags.Token = getenv(gapisTokenEnv)
+	}
+	return gapisFlags, nil
+}
+
 func getGapis(ctx context.Context, gapisFlags GapisFlags, gapirFlags GapirFlags) (client.Client, error) {
+	gapisFlags, err := gapisFlagsFromEnv(gapisFlags, os.Getenv)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Invalid GAPIS connection settings")
+	}
+	if gapisFlags.Host != "" && gapisFlags.Port == 0 {
+		return nil, log.Err(ctx, nil, "A GAPIS host requires a -gapis-port, or $"+gapisPortEnv)
+	}
+
 	args := strings.Fields(gapisFlags.Args)
 
 	args = append(args, "--enable-local-files")
@@ -93,12 +133,13 @@ func getGapis(ctx context.Context, gapisFlags GapisFlags, gapirFlags GapirFlags)
 	}
 
 	var token auth.Token
-	if gapisFlags.Port == 0 {
+	if gapisFlags.Port == 0 && gapisFlags.Token == "" {
 		token = auth.GenToken()
 	} else {
 		token = auth.Token(gapisFlags.Token)
 	}
 	client, err := client.Connect(ctx, client.Config{
+		Host:  gapisFlags.Host,
 		Port:  gapisFlags.Port,
 		Args:  args,
 		Token: token,
@@ -169,24 +210,25 @@ func getGapis(ctx context.Context, gapisFlags GapisFlags, gapirFlags GapirFlags)
 	return clientCloser{client, close}, nil
 }
 
+// closeGapis closes the connection to gapis, logging the error if it fails, so
+// that it can be deferred.
+func closeGapis(ctx context.Context, c client.Client) {
+	if err := c.Close(); err != nil {
+		log.W(ctx, "Failed to close the connection to the GAPIS server: %v", err)
+	}
+}
+
 // getGapisAndLoadCapture connects to or creates a gapis server and loads a capture file or capture ID (depending on the CaptureFileFlags).
 // It returns the client rpc interface, the loaded path.Capture, and an error.
 func getGapisAndLoadCapture(ctx context.Context, gapisFlags GapisFlags, gapirFlags GapirFlags, capturePathOrID string, captureFileFlags CaptureFileFlags) (client.Client, *path.Capture, error) {
 
-	var captureID id.ID
-	var capturePath string
-	var capture *path.Capture
-	var err error
-
 	// Check capturePathOrID first.
 	if captureFileFlags.CaptureID {
-		captureID, err = id.Parse(capturePathOrID)
-		if err != nil {
+		if _, err := id.Parse(capturePathOrID); err != nil {
 			return nil, nil, log.Err(ctx, err, "Could not parse capture ID")
 		}
 	} else {
-		capturePath, err = filepath.Abs(capturePathOrID)
-		if err != nil {
+		if _, err := filepath.Abs(capturePathOrID); err != nil {
 			return nil, nil, log.Err(ctx, err, "Could not find capture file")
 		}
 	}
@@ -196,22 +238,39 @@ func getGapisAndLoadCapture(ctx context.Context, gapisFlags GapisFlags, gapirFla
 	if err != nil {
 		return nil, nil, log.Err(ctx, err, "Failed to connect to the GAPIS server")
 	}
-	// Note: call client.Close() if we don't return successfully.
 
-	// Get or load the capture.
+	capture, err := loadCapture(ctx, client, capturePathOrID, captureFileFlags)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return client, capture, nil
+}
+
+// loadCapture loads the capture file, or refers to the already loaded capture
+// with -captureid, on an existing GAPIS connection.
+func loadCapture(ctx context.Context, client client.Client, capturePathOrID string, captureFileFlags CaptureFileFlags) (*path.Capture, error) {
+	var capture *path.Capture
 	if captureFileFlags.CaptureID {
+		captureID, err := id.Parse(capturePathOrID)
+		if err != nil {
+			return nil, log.Err(ctx, err, "Could not parse capture ID")
+		}
 		capture = &path.Capture{ID: path.NewID(captureID)}
 	} else {
+		capturePath, err := filepath.Abs(capturePathOrID)
+		if err != nil {
+			return nil, log.Err(ctx, err, "Could not find capture file")
+		}
 		capture, err = client.LoadCapture(ctx, capturePath)
 		if err != nil {
-			client.Close()
-			return nil, nil, log.Err(ctx, err, "Failed to load the capture file")
+			return nil, log.Err(ctx, err, "Failed to load the capture file")
 		}
 	}
 
 	log.I(ctx, "Loaded capture; id: %s", capture.ID)
 
-	return client, capture, nil
+	return capture, nil
 }
 
 func getDevice(ctx context.Context, client client.Client, capture *path.Capture, flags GapirFlags) (*path.Device, error) {
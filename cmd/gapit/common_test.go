@@ -0,0 +1,55 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestGapisFlagsFromEnv(t *testing.T) {
+	assert := assert.To(t)
+	env := map[string]string{
+		gapisHostEnv:  "gapis.local",
+		gapisPortEnv:  "8080",
+		gapisTokenEnv: "secret",
+	}
+	getenv := func(key string) string { return env[key] }
+
+	got, err := gapisFlagsFromEnv(GapisFlags{}, getenv)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("host").That(got.Host).Equals("gapis.local")
+	assert.For("port").That(got.Port).Equals(8080)
+	assert.For("token").That(got.Token).Equals("secret")
+
+	got, err = gapisFlagsFromEnv(GapisFlags{Host: "other", Port: 9000, Token: "flag"}, getenv)
+	assert.For("flags err").ThatError(err).Succeeded()
+	assert.For("flags host").That(got.Host).Equals("other")
+	assert.For("flags port").That(got.Port).Equals(9000)
+	assert.For("flags token").That(got.Token).Equals("flag")
+
+	delete(env, gapisPortEnv)
+	got, err = gapisFlagsFromEnv(GapisFlags{}, getenv)
+	assert.For("local err").ThatError(err).Succeeded()
+	assert.For("local token").That(got.Token).Equals("")
+	got, err = gapisFlagsFromEnv(GapisFlags{Port: 9000}, getenv)
+	assert.For("flag port err").ThatError(err).Succeeded()
+	assert.For("flag port token").That(got.Token).Equals("secret")
+
+	env[gapisPortEnv] = "http"
+	_, err = gapisFlagsFromEnv(GapisFlags{}, getenv)
+	assert.For("invalid port").ThatError(err).Failed()
+}
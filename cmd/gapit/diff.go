@@ -0,0 +1,245 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/status"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+type diffVerb struct{ DiffFlags }
+
+func init() {
+	verb := &diffVerb{DiffFlags{Max: 100}}
+	app.AddVerb(&app.Verb{
+		Name:       "diff",
+		ShortHelp:  "Compares the command streams of two .gfxtrace files",
+		ShortUsage: "<old gfxtrace> <new gfxtrace>",
+		Action:     verb,
+	})
+}
+
+// maxAlignCells is the largest product of the lengths of the differing middle
+// sections of the command streams that are aligned command by command. Larger
+// sections are reported as entirely removed and added, to bound the memory.
+const maxAlignCells = 1 << 24
+
+// commandSummary is the part of a command that is compared across captures.
+type commandSummary struct {
+	name   string
+	params string
+}
+
+// commandEdit is a single step of the alignment of two command streams: a
+// command of the old stream that is kept in (op '='), or removed from (op '-')
+// the new stream, or a command added to the new stream (op '+').
+type commandEdit struct {
+	op       byte
+	old, new int
+}
+
+func (verb *diffVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 2 {
+		app.Usage(ctx, "Exactly two gfx trace files expected, got %d", flags.NArg())
+		return nil
+	}
+
+	client, oldCapture, err := getGapisAndLoadCapture(ctx, verb.Gapis, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+	defer closeGapis(ctx, client)
+	newCapture, err := loadCapture(ctx, client, flags.Arg(1), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+
+	oldCmds, err := getCommandSummaries(ctx, client, oldCapture)
+	if err != nil {
+		return err
+	}
+	newCmds, err := getCommandSummaries(ctx, client, newCapture)
+	if err != nil {
+		return err
+	}
+
+	oldNames, newNames := make([]string, len(oldCmds)), make([]string, len(newCmds))
+	for i, c := range oldCmds {
+		oldNames[i] = c.name
+	}
+	for i, c := range newCmds {
+		newNames[i] = c.name
+	}
+	edits := alignCommands(oldNames, newNames)
+
+	// Commands aligned by name are only reported with -params, if their
+	// parameters differ.
+	diffs := []commandEdit{}
+	matched, changed, removed, added := 0, 0, 0, 0
+	for _, e := range edits {
+		switch e.op {
+		case '=':
+			if verb.Params && oldCmds[e.old].params != newCmds[e.new].params {
+				changed++
+				diffs = append(diffs, commandEdit{'~', e.old, e.new})
+			} else {
+				matched++
+			}
+		case '-':
+			removed++
+			diffs = append(diffs, e)
+		case '+':
+			added++
+			diffs = append(diffs, e)
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "%v commands in %v, %v in %v\n", len(oldCmds), flags.Arg(0), len(newCmds), flags.Arg(1))
+	fmt.Fprintf(os.Stdout, "%v matched, %v changed, %v removed, %v added\n", matched, changed, removed, added)
+	if len(diffs) == 0 {
+		fmt.Fprintln(os.Stdout, "The command streams are identical")
+		return nil
+	}
+
+	first := diffs[0]
+	fmt.Fprintf(os.Stdout, "First divergence at command %v of %v, command %v of %v\n",
+		first.old, flags.Arg(0), first.new, flags.Arg(1))
+	for i, d := range diffs {
+		if verb.Max > 0 && i == verb.Max {
+			fmt.Fprintf(os.Stdout, "... %v more differences\n", len(diffs)-i)
+			break
+		}
+		switch d.op {
+		case '~':
+			fmt.Fprintf(os.Stdout, "~ [%v -> %v] %v\n", d.old, d.new, newCmds[d.new].name)
+			fmt.Fprintf(os.Stdout, "\t- %v\n", oldCmds[d.old].params)
+			fmt.Fprintf(os.Stdout, "\t+ %v\n", newCmds[d.new].params)
+		case '-':
+			fmt.Fprintf(os.Stdout, "- [%v] %v\n", d.old, oldCmds[d.old].name)
+		case '+':
+			fmt.Fprintf(os.Stdout, "+ [%v] %v\n", d.new, newCmds[d.new].name)
+		}
+	}
+	return nil
+}
+
+// getCommandSummaries returns the name and parameters of each of the top
+// level commands of the capture.
+func getCommandSummaries(ctx context.Context, client service.Service, capture *path.Capture) ([]commandSummary, error) {
+	numCommands, err := getNumCommands(ctx, client, capture)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = status.Start(ctx, "Loading %v commands", numCommands)
+	defer status.Finish(ctx)
+	out := make([]commandSummary, numCommands)
+	for i := uint64(0); i < numCommands; i++ {
+		if task.Stopped(ctx) {
+			return nil, task.StopReason(ctx)
+		}
+		status.UpdateProgress(ctx, i, numCommands)
+		cmd, err := getCommand(ctx, client, capture.Command(i))
+		if err != nil {
+			return nil, err
+		}
+		params := make([]string, len(cmd.Parameters))
+		for j, p := range cmd.Parameters {
+			params[j] = fmt.Sprintf("%v: %v", p.Name, p.Value.Get())
+		}
+		out[i] = commandSummary{cmd.Name, strings.Join(params, ", ")}
+	}
+	return out, nil
+}
+
+// alignCommands returns the edits turning the old command stream into the new
+// one, matching commands by name. The common prefix and suffix are matched
+// first, then the differing middle section is aligned using the longest
+// common subsequence, unless it is larger than maxAlignCells.
+func alignCommands(old, new []string) []commandEdit {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix &&
+		old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]commandEdit, 0, len(old)+len(new))
+	for i := 0; i < prefix; i++ {
+		edits = append(edits, commandEdit{'=', i, i})
+	}
+
+	a, b := old[prefix:len(old)-suffix], new[prefix:len(new)-suffix]
+	n, m := len(a), len(b)
+	if n*m > maxAlignCells {
+		for i := range a {
+			edits = append(edits, commandEdit{'-', prefix + i, prefix})
+		}
+		for j := range b {
+			edits = append(edits, commandEdit{'+', prefix + n, prefix + j})
+		}
+	} else {
+		// lcs[i][j] is the length of the longest common subsequence of a[i:]
+		// and b[j:].
+		lcs := make([][]int32, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int32, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				switch {
+				case a[i] == b[j]:
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				case lcs[i+1][j] >= lcs[i][j+1]:
+					lcs[i][j] = lcs[i+1][j]
+				default:
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < n || j < m {
+			switch {
+			case i < n && j < m && a[i] == b[j]:
+				edits = append(edits, commandEdit{'=', prefix + i, prefix + j})
+				i++
+				j++
+			case j == m || (i < n && lcs[i+1][j] >= lcs[i][j+1]):
+				edits = append(edits, commandEdit{'-', prefix + i, prefix + j})
+				i++
+			default:
+				edits = append(edits, commandEdit{'+', prefix + i, prefix + j})
+				j++
+			}
+		}
+	}
+
+	for k := suffix; k > 0; k-- {
+		edits = append(edits, commandEdit{'=', len(old) - k, len(new) - k})
+	}
+	return edits
+}
@@ -0,0 +1,37 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestAlignCommands(t *testing.T) {
+	assert := assert.To(t)
+	old := []string{"a", "b", "c", "d", "e"}
+	new := []string{"a", "c", "x", "d", "e", "f"}
+	assert.For("edits").That(alignCommands(old, new)).DeepEquals([]commandEdit{
+		{'=', 0, 0},
+		{'-', 1, 1},
+		{'=', 2, 1},
+		{'+', 3, 2},
+		{'=', 3, 3},
+		{'=', 4, 4},
+		{'+', 5, 5},
+	})
+	assert.For("identical").That(len(alignCommands(old, old))).Equals(len(old))
+}
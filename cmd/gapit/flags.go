@@ -15,10 +15,13 @@
 package main
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/gapid/core/app/flags"
 	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/core/text"
 )
 
 const (
@@ -136,6 +139,53 @@ func (v PerfettoOutputFormat) String() string {
 	return PerfettoOutputFormatNames[v]
 }
 
+// ByteCount is a flag holding a number of bytes. It accepts unit suffixes,
+// e.g. 512K or 16M.
+type ByteCount uint64
+
+func (b *ByteCount) String() string {
+	return fmt.Sprint(uint64(*b))
+}
+func (b *ByteCount) Set(v string) error {
+	n, err := text.ParseBytes(v)
+	if err != nil {
+		return err
+	}
+	*b = ByteCount(n)
+	return nil
+}
+
+// CommandPoint is a flag holding a command/subcommand index, e.g. 42 or
+// [42,3], or a label resolved in the capture, e.g. last, frame:3 or
+// draw:Shadows.
+type CommandPoint struct {
+	Indices flags.U64Slice
+	Label   string
+}
+
+func (p *CommandPoint) String() string {
+	if p.Label != "" {
+		return p.Label
+	}
+	return p.Indices.String()
+}
+func (p *CommandPoint) Set(v string) error {
+	if v == "first" || v == "last" {
+		p.Indices, p.Label = nil, v
+		return nil
+	}
+	if i := strings.Index(v, ":"); i >= 0 {
+		switch v[:i] {
+		case "frame", "draw":
+			p.Indices, p.Label = nil, v
+			return nil
+		}
+		return fmt.Errorf("Unknown command label %q, expected frame:N or draw:NAME", v)
+	}
+	p.Label = ""
+	return p.Indices.Set(v)
+}
+
 type (
 	CaptureFileFlags struct {
 		CaptureID bool `help:"if true then interpret the capture file argument as a capture ID that is already loaded in gapis"`
@@ -166,9 +216,10 @@ type (
 	}
 	GapisFlags struct {
 		Profile    ProfileFlags
-		Port       int    `help:"gapis tcp port to connect to, 0 means start new instance."`
+		Host       string `help:"gapis host to connect to with -port, localhost if empty. Defaults to $GAPID_GAPIS_HOST"`
+		Port       int    `help:"gapis tcp port to connect to, 0 means start new instance. Defaults to $GAPID_GAPIS_PORT"`
 		Args       string `help:"_The arguments to be passed to gapis"`
-		Token      string `help:"_The auth token to use when connecting to an existing server."`
+		Token      string `help:"_The auth token to use when connecting to an existing server. Defaults to $GAPID_GAPIS_TOKEN"`
 		DisableLog bool   `help:"_Disable the log output"`
 	}
 	GapirFlags struct {
@@ -369,6 +420,9 @@ type (
 			Overdraw int `help:"the amount of overdraw to map to white in the output"`
 		}
 		DisplayToSurface bool `help:"display the frames rendered in the replay back to the surface"`
+		Mem              struct {
+			Overlay bool `help:"draw the total size of the memory allocations and the largest allocation over the screenshot"`
+		}
 		CommandFilterFlags
 		CaptureFileFlags
 	}
@@ -376,9 +430,164 @@ type (
 		Verbose bool `help:"if true, then output will not be truncated"`
 	}
 
-	MemoryFlags struct {
+	ImagesFlags struct {
 		Gapis GapisFlags
-		At    flags.U64Slice `help:"command/subcommand index to get the memory after. Empty for last"`
+		At    []flags.U64Slice `help:"command/subcommand index to get the images after (repeatable). Empty for last"`
+		Json  bool             `help:"print the images as JSON instead of text"`
+		CaptureFileFlags
+	}
+	BuffersFlags struct {
+		Gapis GapisFlags
+		At    flags.U64Slice `help:"command/subcommand index to get the buffers after. Empty for last"`
+		CaptureFileFlags
+	}
+	MetricsFlags struct {
+		Gapis GapisFlags
+		At    flags.U64Slice `help:"command/subcommand index to get the metrics after. Empty for last"`
+		Json  bool           `help:"print the metrics as JSON instead of a table"`
+		CaptureFileFlags
+	}
+	MemoryFlags struct {
+		Gapis  GapisFlags
+		At     []CommandPoint `help:"command/subcommand index, first, last, frame:N (1-based) or draw:NAME (command tree group) to get the memory after (repeatable). Empty for last"`
+		Json   bool           `help:"print the memory breakdown as JSON instead of text"`
+		Csv    bool           `help:"print one CSV row per binding instead of text"`
+		Watch  int            `help:"print the total size and count of the allocations every N commands"`
+		Peak   bool           `help:"print the memory breakdown at the command with the largest total size, sampled every -watch commands, at the end of every frame with -at-each-frame, or every -peak-stride commands"`
+		Dot    bool           `help:"print the allocations and bindings as a Graphviz DOT graph instead of text"`
+		Diff   bool           `help:"print only the changes between the two -at points"`
+		Since  flags.U64Slice `help:"print only the allocations added or grown since this command/subcommand index, up to the -at point"`
+		Out    string         `help:"write the report to this file instead of stdout"`
+		Unused bool           `help:"only print the allocations without any memory bound, and the total size they waste"`
+		Leaks  bool           `help:"print the allocations live at the second -at point that were created after the first one, largest first. Default first and last command"`
+		Format string         `help:"print each allocation with a Go text/template, or @file to read it from a file. Helpers: humanBytes, hex"`
+		Quiet  bool           `help:"don't print the 'No aliased regions' lines and the empty sections, and only log warnings and errors"`
+		V      bool           `help:"print extra details, such as the raw flag bitmasks along with their names. Implied by -log-level Debug"`
+		Api    string         `help:"resolve the allocation flag names with the constants of this API, vulkan or gles, instead of the API of the memory breakdown"`
+		Memory struct {
+			Type string `help:"only print allocations from the given memory types (comma-separated)"`
+		}
+		Device   string `help:"only print allocations on the given devices (comma-separated)"`
+		Resource string `help:"only print the allocations, bindings and aliases of the given resource handles (comma-separated, e.g. 0x1234)"`
+		Perfetto string `help:"with -watch or -at-each-frame, also write the samples as counter tracks to this Chrome trace JSON file, for Perfetto"`
+		Validate bool   `help:"print the bindings that extend past the end of their allocation, and fail if there are any"`
+		Assert   string `help:"check the allocations kept by the filter against the rules of this file, e.g. total < 1GiB, and exit with code 5 if any fails"`
+		Treemap  string `help:"write the allocations as an SVG treemap to this file, a row per memory type, colored by memory type, with their bindings"`
+		Layers   string `help:"print a matrix of the memory bound to each mip level and array layer, per aspect, of the image resource with this handle"`
+		List     struct {
+			Devices bool `help:"print the devices of the allocations, with their allocation count and total size"`
+		}
+		Page struct {
+			Size ByteCount `help:"only print the bindings whose offset or size isn't a multiple of this page size, e.g. 64K"`
+		}
+		Find struct {
+			Resource string `help:"print the allocation, offset, size and type of each binding of the resource with this handle"`
+		}
+		Baseline struct {
+			Save      string  `help:"save the unfiltered memory breakdown at the -at point to this JSON file, to compare against with -baseline-load"`
+			Load      string  `help:"only print the regressions over the baseline saved to this file: the added allocations, and the ones that grew over -baseline-tolerance. Exit with code 6 if there are any"`
+			Tolerance float64 `help:"with -baseline-load, the growth in percent of the allocations and total size that is not a regression"`
+		}
+		Binding struct {
+			Type        string `help:"only print the bindings of the given types (comma-separated), e.g. buffer,sparse-image-block"`
+			TypeAliases bool   `name:"type-aliases" help:"with -binding-type, only print the aliased regions shared by a binding of the given types"`
+		}
+		Include struct {
+			Name string `help:"only print the allocations whose name matches this regular expression"`
+		}
+		Exclude struct {
+			Name string `help:"don't print the allocations whose name matches this regular expression, e.g. ^Internal"`
+		}
+		Follow struct {
+			Resource string `help:"print a timeline of the bindings of the resource with this handle at each -at point, every -watch commands, at the end of every frame with -at-each-frame, or every command"`
+		}
+		Min struct {
+			Size    ByteCount `help:"only print allocations of at least this size, e.g. 16M"`
+			Width   int       `help:"the minimum width of the columns of the text output, default 4"`
+			Binding struct {
+				Size ByteCount `help:"only print bindings of at least this size, e.g. 512K"`
+			}
+		}
+		Color     string `help:"highlight aliased regions and large allocations: auto (if stdout is a terminal), always or never"`
+		Highlight struct {
+			Size ByteCount `help:"allocations of at least this size are highlighted by -color, 0 to disable"`
+		}
+		Raw struct {
+			Bytes bool `help:"print sizes as exact byte counts instead of human readable sizes"`
+		}
+		Alias struct {
+			Pairs bool `help:"also print each pair of aliased bindings with the exact range they share"`
+		}
+		Compact struct {
+			Aliases bool `help:"print a one line summary of the aliased regions of each allocation instead of each region, also with -no-bindings"`
+		}
+		Alloc struct {
+			ID bool `help:"print the stable id of each allocation, made of its handle and allocating command, which tells apart an allocation from a freed one whose handle it reused. -diff, -since and -leaks always match allocations by id"`
+		}
+		Hex struct {
+			Handles bool `help:"print the allocation, binding and alias sharer handles in hex"`
+		}
+		Column struct {
+			Padding int `help:"the number of spaces between the columns of the text output, default 1 or 2 for tables"`
+		}
+		Dump struct {
+			Raw struct {
+				Metrics bool `help:"print the api.Metrics of each -at point in the protobuf text format, as sent by GAPIS, without interpreting them"`
+			}
+		}
+		Compare struct {
+			Files bool `help:"print the changes to the allocations from the first to the second capture file, at the -at point of each, matching the allocations by name, memory type and size"`
+		}
+		Metrics struct {
+			File string `help:"read the memory breakdown from a binary api.Metrics protobuf file instead of a capture"`
+		}
+		Export struct {
+			Bindings string `help:"write the memory breakdown, with all the allocations and bindings, to this binary api.Metrics protobuf file, readable with -metrics-file"`
+		}
+		Group struct {
+			By struct {
+				Type bool `help:"group the allocations by memory type, with a size subtotal per type"`
+			}
+		}
+		Show struct {
+			Heap struct {
+				Usage bool `help:"print each allocation's size as a percentage of its memory heap"`
+			}
+		}
+		Sort         string `help:"sort the allocations by handle, size, name, type or bindings (binding count), or by several keys with a direction each, e.g. size:desc,name:asc. Default handle"`
+		Desc         bool   `help:"reverse the order of the -sort keys without a direction"`
+		SortBindings string `name:"sort-bindings" help:"sort the bindings by offset, size, handle, name or type. Default offset"`
+		SortSharers  string `name:"sort-sharers" help:"sort the sharers of the aliased regions by handle or name (falling back to handle). Default handle"`
+		No           struct {
+			Bindings bool `help:"only print the allocation headers, without the bindings and aliased regions"`
+			Flag     struct {
+				Names bool `help:"don't resolve the names of the allocation flags, saving a request, and print them in hex"`
+			}
+		}
+		Summary struct {
+			Only bool `help:"only print the totals of the allocations, bindings and aliased regions kept by the filters"`
+		}
+		Sparse struct {
+			Grid bool `help:"also print the bound blocks of each sparse image as a grid per mip level and array layer"`
+		}
+		Coalesce      bool `help:"merge the contiguous bindings of the same resource and type"`
+		Histogram     bool `help:"print the number of bindings of each type and their size distribution instead of each binding"`
+		Fragmentation bool `help:"print the size of the gaps between bindings as a percentage of each allocation"`
+		Top           int  `help:"only print the N largest allocations, one line per allocation"`
+		Verbose       bool `help:"print the full details of the allocations selected by -top"`
+		Limit         int  `help:"only print N allocations, after the ones skipped by -offset. The totals are of the printed allocations"`
+		Offset        int  `help:"skip the first N allocations, in the -sort order, to page through them with -limit"`
+		Concurrency   int  `help:"the maximum number of memory breakdowns fetched at once"`
+		PeakStride    int  `name:"peak-stride" help:"with -peak, sample the memory every N commands if neither -watch nor -at-each-frame is set"`
+		JsonStream    bool `name:"json-stream" help:"print the memory breakdown as newline-delimited JSON, one allocation per line, for very large captures"`
+		EachFrame     bool `name:"at-each-frame" help:"print the total size and count of the allocations at the end of every frame, like -watch"`
+		Fail          struct {
+			Over ByteCount `help:"exit with code 3 if the allocations kept by the filter total more than this size, e.g. 512M"`
+			On   struct {
+				Alias bool `help:"exit with code 4 if any allocation has hard (non-sparse) aliased bindings"`
+			}
+		}
+		ResourceSummary bool `name:"resource-summary" help:"also print the number of bindings and total bound size of each resource, largest first"`
 		CaptureFileFlags
 	}
 	PipelineFlags struct {
@@ -456,4 +665,16 @@ type (
 		To   uint64 `help:"The exclusive end index of the command range. Default: 0 (last command)"`
 		Out  string `help:"Output file."`
 	}
+
+	ShellFlags struct {
+		Gapis GapisFlags
+		CaptureFileFlags
+	}
+
+	DiffFlags struct {
+		Gapis  GapisFlags
+		Params bool `help:"also report the aligned commands whose parameters differ"`
+		Max    int  `help:"the maximum number of differences to print, 0 for all. Default: 100"`
+		CaptureFileFlags
+	}
 )
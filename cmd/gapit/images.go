@@ -0,0 +1,239 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/data/protoutil"
+	"github.com/google/gapid/core/image"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+type imagesVerb ImagesFlags
+
+func init() {
+	verb := &imagesVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "images",
+		ShortHelp: "Prints a summary of the image resources of a capture file",
+		Action:    verb,
+	})
+}
+
+func (verb *imagesVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	client, capture, err := getGapisAndLoadCapture(ctx, verb.Gapis, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	numCommands, err := getNumCommands(ctx, client, capture)
+	if err != nil {
+		return err
+	}
+	if len(verb.At) == 0 {
+		verb.At = append(verb.At, []uint64{numCommands - 1})
+	}
+	for _, at := range verb.At {
+		if err := checkCommandIndex(ctx, at, numCommands); err != nil {
+			return err
+		}
+	}
+
+	boxedResources, err := client.Get(ctx, capture.Resources().Path(), nil)
+	if err != nil {
+		return log.Err(ctx, err, "Could not find the capture's resources")
+	}
+	textures := []*service.Resource{}
+	for _, types := range boxedResources.(*service.Resources).Types {
+		if types.Type == api.ResourceType_TextureResource {
+			textures = append(textures, types.Resources...)
+		}
+	}
+	sort.Slice(textures, func(i, j int) bool {
+		return handleLess(textures[i].Handle, textures[j].Handle)
+	})
+
+	for i, at := range verb.At {
+		cmd := capture.Command(at[0], at[1:]...)
+		images := []imageJSON{}
+		for _, res := range textures {
+			if !res.ID.IsValid() || !isLive(res, at[0]) {
+				continue
+			}
+			boxedData, err := client.Get(ctx, cmd.ResourceAfter(res.ID).Path(), nil)
+			if err != nil {
+				log.E(ctx, "Could not get data for image %v: %v", res.Handle, err)
+				continue
+			}
+			if tex := boxedData.(*api.ResourceData).GetTexture(); tex != nil {
+				images = append(images, newImageJSON(res, tex))
+			}
+		}
+
+		if verb.Json {
+			if err := printImagesJSON(ctx, cmd, images); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(verb.At) > 1 {
+			if i > 0 {
+				fmt.Fprintln(os.Stdout)
+			}
+			fmt.Fprintf(os.Stdout, "Images at command %v:\n", cmd.Indices)
+		}
+		printImages(images)
+	}
+	return nil
+}
+
+// handleLess orders resource handles numerically when they are both numbers,
+// and lexically otherwise.
+func handleLess(a, b string) bool {
+	x, errX := strconv.ParseUint(a, 0, 64)
+	y, errY := strconv.ParseUint(b, 0, 64)
+	if errX == nil && errY == nil {
+		return x < y
+	}
+	return a < b
+}
+
+// isLive returns true if the resource exists after the command at.
+func isLive(res *service.Resource, at uint64) bool {
+	if c := res.Created; c != nil && len(c.Indices) > 0 && c.Indices[0] > at {
+		return false
+	}
+	if d := res.Deleted; d != nil && len(d.Indices) > 0 && d.Indices[0] <= at {
+		return false
+	}
+	return true
+}
+
+// imagesJSON is the JSON representation of the images at a single command.
+type imagesJSON struct {
+	Command []uint64    `json:"command"`
+	Images  []imageJSON `json:"images"`
+}
+
+// imageJSON is the summary of a single image resource. The image usage flags
+// and layout are not part of the texture resource data, so are not included.
+type imageJSON struct {
+	Handle       string `json:"handle"`
+	Label        string `json:"label,omitempty"`
+	Type         string `json:"type"`
+	Width        uint32 `json:"width"`
+	Height       uint32 `json:"height"`
+	Depth        uint32 `json:"depth"`
+	Format       string `json:"format"`
+	MipLevels    int    `json:"mipLevels"`
+	ArrayLayers  int    `json:"arrayLayers"`
+	Multisampled bool   `json:"multisampled"`
+}
+
+// newImageJSON summarizes the texture data of the image resource res. The
+// extent and format are those of the first mip level of the first layer.
+func newImageJSON(res *service.Resource, tex *api.Texture) imageJSON {
+	img := imageJSON{Handle: res.Handle, Label: res.Label, ArrayLayers: 1}
+	var levels []*image.Info
+	switch t := protoutil.OneOf(tex.Type).(type) {
+	case *api.Texture1D:
+		img.Type, levels = "1D", t.Levels
+	case *api.Texture1DArray:
+		img.Type, img.ArrayLayers = "1D Array", len(t.Layers)
+		if len(t.Layers) > 0 {
+			levels = t.Layers[0].Levels
+		}
+	case *api.Texture2D:
+		img.Type, levels, img.Multisampled = "2D", t.Levels, t.Multisampled
+	case *api.Texture2DArray:
+		img.Type, img.ArrayLayers, img.Multisampled = "2D Array", len(t.Layers), t.Multisampled
+		if len(t.Layers) > 0 {
+			levels = t.Layers[0].Levels
+		}
+	case *api.Texture3D:
+		img.Type, levels = "3D", t.Levels
+	case *api.Cubemap:
+		img.Type, levels, img.ArrayLayers = "Cubemap", cubemapLevels(t), 6
+	case *api.CubemapArray:
+		img.Type, img.ArrayLayers = "Cubemap Array", 6*len(t.Layers)
+		if len(t.Layers) > 0 {
+			levels = cubemapLevels(t.Layers[0])
+		}
+	}
+
+	img.MipLevels = len(levels)
+	if len(levels) > 0 && levels[0] != nil {
+		img.Width, img.Height, img.Depth = levels[0].Width, levels[0].Height, levels[0].Depth
+		img.Format = levels[0].GetFormat().GetName()
+	}
+	return img
+}
+
+// cubemapLevels returns the mip levels of the first face of the cubemap.
+func cubemapLevels(c *api.Cubemap) []*image.Info {
+	levels := make([]*image.Info, len(c.Levels))
+	for i, l := range c.Levels {
+		levels[i] = l.NegativeX
+	}
+	return levels
+}
+
+func printImages(images []imageJSON) {
+	w := tabwriter.NewWriter(os.Stdout, 4, 4, 0, ' ', 0)
+	fmt.Fprintf(w, "%v images\n", len(images))
+	for _, img := range images {
+		if img.Label != "" {
+			fmt.Fprintf(w, "Name: %v (%v)\n", img.Handle, img.Label)
+		} else {
+			fmt.Fprintln(w, "Name:", img.Handle)
+		}
+		fmt.Fprintf(w, "\tType: \t%v\n", img.Type)
+		fmt.Fprintf(w, "\tExtent: \t%vx%vx%v\n", img.Width, img.Height, img.Depth)
+		fmt.Fprintf(w, "\tFormat: \t%v\n", img.Format)
+		fmt.Fprintf(w, "\tMip Levels: \t%v\n", img.MipLevels)
+		fmt.Fprintf(w, "\tArray Layers: \t%v\n", img.ArrayLayers)
+		if img.Multisampled {
+			fmt.Fprintln(w, "\tMultisampled")
+		}
+	}
+	w.Flush()
+}
+
+func printImagesJSON(ctx context.Context, cmd *path.Command, images []imageJSON) error {
+	out, err := json.MarshalIndent(imagesJSON{cmd.Indices, images}, "", "  ")
+	if err != nil {
+		return log.Err(ctx, err, "Failed to marshal images to JSON")
+	}
+	fmt.Fprintln(os.Stdout, string(out))
+	return nil
+}
@@ -15,254 +15,1853 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/flags"
+	"github.com/google/gapid/core/app/status"
+	"github.com/google/gapid/core/event/task"
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/client"
+	"github.com/google/gapid/gapis/memory/breakdown"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
 )
 
-type memoryVerb MemoryFlags
+type memoryVerb struct {
+	MemoryFlags
+
+	// out is where the report is written, stdout or the -out file.
+	out io.Writer
+	// gapis is the connection to GAPIS shared by all the captures, nil with
+	// -metrics-file.
+	gapis client.Client
+	// assertRules are the rules loaded from the -assert file.
+	assertRules []assertRule
+	// flagNames caches the names of the allocation flags, see flagNameCache.
+	flagNames *breakdown.FlagNameCache
+	// traceDevice is the GPU and driver the current capture was traced on,
+	// resolved along with the capture, empty if unknown. It is printed with
+	// traceDeviceID, if known, the device of all the allocations.
+	traceDevice      string
+	traceDeviceID    uint64
+	traceDeviceKnown bool
+	// allocationCounts are the number of allocations of each of the current
+	// capture's snapshots kept by the filter, before -offset and -limit.
+	allocationCounts []int
+}
 
 func init() {
-	verb := &memoryVerb{}
+	verb := &memoryVerb{MemoryFlags: MemoryFlags{Color: "auto", Concurrency: 4, PeakStride: 100}}
+	verb.Highlight.Size = 64 * 1024 * 1024
 	app.AddVerb(&app.Verb{
-		Name:      "memory",
-		ShortHelp: "Prints memory metrics about a capture file",
-		Action:    verb,
+		Name:       "memory",
+		ShortHelp:  "Prints memory metrics about a capture file",
+		ShortUsage: "<gfxtrace...> (or - to read a single capture from stdin)",
+		Action:     verb,
 	})
 }
 
 func (verb *memoryVerb) Run(ctx context.Context, flags flag.FlagSet) error {
-	if flags.NArg() != 1 {
-		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+	if verb.Metrics.File != "" {
+		if flags.NArg() != 0 {
+			app.Usage(ctx, "No gfx trace file expected with -metrics-file, got %d", flags.NArg())
+			return nil
+		}
+	} else if flags.NArg() == 0 {
+		app.Usage(ctx, "At least one gfx trace file expected")
 		return nil
 	}
 
-	client, capture, err := getGapisAndLoadCapture(ctx, verb.Gapis, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
-	if err != nil {
-		return err
+	if verb.Compare.Files {
+		if flags.NArg() != 2 || len(verb.At) > 1 {
+			app.Usage(ctx, "-compare-files requires exactly two gfx trace files and at most one -at point")
+			return nil
+		}
+		if verb.Diff || len(verb.Since) != 0 || verb.Leaks || verb.Watch > 0 || verb.Peak || verb.EachFrame || verb.Metrics.File != "" || verb.Json || verb.Csv || verb.Dot || verb.Format != "" {
+			app.Usage(ctx, "-compare-files can't be used with -diff, -since, -leaks, -watch, -peak, -at-each-frame, -metrics-file, -json, -csv, -dot or -format")
+			return nil
+		}
 	}
-	defer client.Close()
 
-	if len(verb.At) == 0 {
-		boxedCapture, err := client.Get(ctx, capture.Path(), nil)
-		if err != nil {
-			return log.Err(ctx, err, "Failed to load the capture")
+	if verb.Quiet && verb.V {
+		app.Usage(ctx, "-quiet can't be used with -v")
+		return nil
+	}
+	if verb.Quiet && app.Flags.Log.Level < log.Warning {
+		ctx = log.PutFilter(ctx, log.SeverityFilter(log.Warning))
+	}
+
+	if verb.Dump.Raw.Metrics && (verb.Watch > 0 || verb.Peak || verb.EachFrame || verb.Compare.Files || verb.Metrics.File != "") {
+		app.Usage(ctx, "-dump-raw-metrics can't be used with -watch, -peak, -at-each-frame, -compare-files or -metrics-file")
+		return nil
+	}
+
+	if verb.Diff && (len(verb.At) != 2 || verb.Metrics.File != "") {
+		app.Usage(ctx, "-diff requires exactly two -at points, got %d", len(verb.At))
+		return nil
+	}
+
+	if len(verb.Since) != 0 && (len(verb.At) > 1 || verb.Diff || verb.Metrics.File != "") {
+		app.Usage(ctx, "-since requires at most one -at point, and can't be used with -diff or -metrics-file")
+		return nil
+	}
+
+	if (verb.Watch > 0 || verb.Peak || verb.EachFrame) && (len(verb.At) != 0 || len(verb.Since) != 0 || verb.Diff || verb.Leaks || verb.Metrics.File != "") {
+		app.Usage(ctx, "-watch, -at-each-frame and -peak can't be used with -at, -since, -diff, -leaks or -metrics-file")
+		return nil
+	}
+
+	if verb.EachFrame && verb.Watch > 0 {
+		app.Usage(ctx, "-at-each-frame can't be used with -watch")
+		return nil
+	}
+	if verb.PeakStride <= 0 {
+		app.Usage(ctx, "-peak-stride must be positive, got %d", verb.PeakStride)
+		return nil
+	}
+
+	if verb.Leaks {
+		if (len(verb.At) != 0 && len(verb.At) != 2) || verb.Metrics.File != "" {
+			app.Usage(ctx, "-leaks requires zero or two -at points, got %d", len(verb.At))
+			return nil
+		}
+		if verb.Diff || len(verb.Since) != 0 || verb.Csv || verb.Dot || verb.Format != "" {
+			app.Usage(ctx, "-leaks can't be used with -diff, -since, -csv, -dot or -format")
+			return nil
+		}
+	}
+
+	if verb.Perfetto != "" && verb.Watch == 0 && !verb.EachFrame {
+		app.Usage(ctx, "-perfetto requires -watch or -at-each-frame")
+		return nil
+	}
+
+	if verb.Export.Bindings != "" && (len(verb.At) > 1 || len(verb.Since) != 0 || verb.Diff || verb.Leaks || verb.Metrics.File != "") {
+		app.Usage(ctx, "-export-bindings supports a single -at point, and can't be used with -since, -diff, -leaks or -metrics-file")
+		return nil
+	}
+
+	if verb.Follow.Resource != "" {
+		if verb.Peak || verb.Diff || len(verb.Since) != 0 || verb.Leaks || verb.Compare.Files || verb.Metrics.File != "" || verb.Json || verb.Csv || verb.Dot || verb.Format != "" {
+			app.Usage(ctx, "-follow-resource can't be used with -peak, -diff, -since, -leaks, -compare-files, -metrics-file, -json, -csv, -dot or -format")
+			return nil
+		}
+		if len(verb.At) != 0 && (verb.Watch > 0 || verb.EachFrame) {
+			app.Usage(ctx, "-follow-resource samples either the -at points, every -watch commands or every frame")
+			return nil
+		}
+	}
+
+	if verb.Baseline.Save != "" || verb.Baseline.Load != "" {
+		if verb.Baseline.Save != "" && verb.Baseline.Load != "" {
+			app.Usage(ctx, "-baseline-save can't be used with -baseline-load")
+			return nil
+		}
+		if len(verb.At) > 1 || verb.Diff || len(verb.Since) != 0 || verb.Leaks || verb.Watch > 0 || verb.Peak || verb.EachFrame || verb.Compare.Files || verb.Json || verb.Csv || verb.Dot || verb.Format != "" {
+			app.Usage(ctx, "-baseline-save and -baseline-load support a single -at point, and can't be used with -diff, -since, -leaks, -watch, -peak, -at-each-frame, -compare-files, -json, -csv, -dot or -format")
+			return nil
+		}
+	}
+	if verb.Baseline.Tolerance < 0 {
+		app.Usage(ctx, "-baseline-tolerance can't be negative, got %v", verb.Baseline.Tolerance)
+		return nil
+	}
+
+	if verb.Limit < 0 || verb.Offset < 0 {
+		app.Usage(ctx, "-limit and -offset can't be negative")
+		return nil
+	}
+	if (verb.Limit > 0 || verb.Offset > 0) && (verb.Diff || len(verb.Since) != 0 || verb.Leaks || verb.Watch > 0 || verb.Peak || verb.EachFrame || verb.Compare.Files || verb.Follow.Resource != "" || verb.Baseline.Save != "" || verb.Baseline.Load != "") {
+		app.Usage(ctx, "-limit and -offset can't be used with -diff, -since, -leaks, -watch, -peak, -at-each-frame, -compare-files, -follow-resource, -baseline-save or -baseline-load")
+		return nil
+	}
+
+	if verb.JsonStream && (verb.Json || verb.Csv || verb.Dot || verb.Format != "" || verb.Diff || len(verb.Since) != 0 || verb.Leaks || verb.Unused || verb.Find.Resource != "" || verb.Layers != "" || verb.Page.Size > 0 || verb.Validate || verb.Baseline.Load != "" || verb.Treemap != "") {
+		app.Usage(ctx, "-json-stream can't be used with -json, -csv, -dot, -format, -diff, -since, -leaks, -unused, -find-resource, -layers, -page-size, -validate, -baseline-load or -treemap")
+		return nil
+	}
+
+	if verb.Validate && (verb.Unused || verb.Json || verb.Csv || verb.Dot || verb.Format != "" || verb.Diff || len(verb.Since) != 0 || verb.Leaks) {
+		app.Usage(ctx, "-validate can't be used with -unused, -json, -csv, -dot, -format, -diff, -since or -leaks")
+		return nil
+	}
+
+	if verb.Assert != "" && (verb.Watch > 0 || verb.Peak || verb.EachFrame || verb.Compare.Files) {
+		app.Usage(ctx, "-assert can't be used with -watch, -peak, -at-each-frame or -compare-files")
+		return nil
+	}
+
+	if verb.Treemap != "" && (len(verb.At) > 1 || len(verb.Since) != 0 || verb.Diff || verb.Leaks || verb.Unused || verb.Find.Resource != "" || verb.Layers != "" || verb.Page.Size > 0) {
+		app.Usage(ctx, "-treemap supports a single -at point, and can't be used with -since, -diff, -leaks, -unused, -find-resource, -layers or -page-size")
+		return nil
+	}
+
+	if verb.Dot && len(verb.At) > 1 {
+		app.Usage(ctx, "-dot supports a single -at point, got %d", len(verb.At))
+		return nil
+	}
+
+	if verb.Unused && (verb.Json || verb.Csv || verb.Dot || verb.Format != "" || verb.Diff || len(verb.Since) != 0 || verb.Leaks) {
+		app.Usage(ctx, "-unused can't be used with -json, -csv, -dot, -format, -diff, -since or -leaks")
+		return nil
+	}
+
+	if verb.Find.Resource != "" {
+		if verb.Unused || verb.Json || verb.Csv || verb.Dot || verb.Format != "" || verb.Diff || len(verb.Since) != 0 || verb.Leaks {
+			app.Usage(ctx, "-find-resource can't be used with -unused, -json, -csv, -dot, -format, -diff, -since or -leaks")
+			return nil
+		}
+		if _, err := verb.findResourceHandle(); err != nil {
+			app.Usage(ctx, "%v", err)
+			return nil
+		}
+	}
+
+	if verb.Layers != "" {
+		if verb.Find.Resource != "" || verb.Unused || verb.Json || verb.Csv || verb.Dot || verb.Format != "" || verb.Diff || len(verb.Since) != 0 || verb.Leaks {
+			app.Usage(ctx, "-layers can't be used with -find-resource, -unused, -json, -csv, -dot, -format, -diff, -since or -leaks")
+			return nil
+		}
+		if _, err := verb.layersHandle(); err != nil {
+			app.Usage(ctx, "%v", err)
+			return nil
+		}
+	}
+
+	if verb.Page.Size > 0 && (verb.Layers != "" || verb.Find.Resource != "" || verb.Unused || verb.Json || verb.Csv || verb.Dot || verb.Format != "" || verb.Diff || len(verb.Since) != 0 || verb.Leaks) {
+		app.Usage(ctx, "-page-size can't be used with -layers, -find-resource, -unused, -json, -csv, -dot, -format, -diff, -since or -leaks")
+		return nil
+	}
+
+	if verb.ResourceSummary && (verb.Json || verb.Csv || verb.Dot || verb.Format != "" || verb.Diff || len(verb.Since) != 0 || verb.Leaks || verb.Unused || verb.Find.Resource != "" || verb.Layers != "" || verb.Page.Size > 0) {
+		app.Usage(ctx, "-resource-summary only applies to the text output")
+		return nil
+	}
+
+	if verb.Summary.Only && (verb.Json || verb.Csv || verb.Dot || verb.Format != "" || verb.Diff || len(verb.Since) != 0 || verb.Leaks) {
+		app.Usage(ctx, "-summary-only only applies to the text output")
+		return nil
+	}
+
+	if verb.Format != "" {
+		if verb.Json || verb.Csv || verb.Dot || verb.Diff || len(verb.Since) != 0 {
+			app.Usage(ctx, "-format can't be used with -json, -csv, -dot, -diff or -since")
+			return nil
+		}
+		if _, err := verb.formatTemplate(); err != nil {
+			app.Usage(ctx, "Invalid -format template: %v", err)
+			return nil
+		}
+	}
+
+	filter, err := verb.allocationFilter()
+	if err != nil {
+		return log.Err(ctx, err, "Invalid allocation filter")
+	}
+	if err := verb.checkSortKeys(); err != nil {
+		app.Usage(ctx, "%v", err)
+		return nil
+	}
+	if err := verb.checkColor(); err != nil {
+		app.Usage(ctx, "%v", err)
+		return nil
+	}
+	if err := verb.checkAPI(); err != nil {
+		app.Usage(ctx, "%v", err)
+		return nil
+	}
+	if verb.Assert != "" {
+		if err := verb.loadAssertRules(); err != nil {
+			return log.Errf(ctx, err, "Invalid -assert rules file %v", verb.Assert)
 		}
-		verb.At = []uint64{uint64(boxedCapture.(*service.Capture).NumCommands) - 1}
 	}
 
-	boxedVal, err := client.Get(ctx, (&path.Metrics{
-		Command:         capture.Command(verb.At[0], verb.At[1:]...),
-		MemoryBreakdown: true,
-	}).Path(), nil)
+	verb.out = os.Stdout
+	if verb.Out == "" {
+		return verb.run(ctx, flags, filter)
+	}
+	f, err := os.OpenFile(verb.Out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		return log.Errf(ctx, err, "Failed to load metrics")
+		return log.Err(ctx, err, "Failed to open the output file")
+	}
+	verb.out = f
+	err = verb.run(ctx, flags, filter)
+	// The output may only be flushed to the file when it is closed, so the
+	// error of the close is not ignored.
+	if cerr := f.Close(); cerr != nil {
+		if err == nil {
+			return log.Err(ctx, cerr, "Failed to close the output file")
+		}
+		log.W(ctx, "Failed to close the output file: %v", cerr)
 	}
+	return err
+}
 
-	mem := boxedVal.(*api.Metrics).MemoryBreakdown
-	if mem == nil {
-		return log.Errf(ctx, err, "Loaded metrics do not have memory breakdown")
+// run connects to GAPIS, if needed, and runs the verb on the capture files,
+// once the flags have been checked and the output opened.
+func (verb *memoryVerb) run(ctx context.Context, flags flag.FlagSet, filter allocationFilter) error {
+	if verb.Metrics.File == "" {
+		if err := verb.connectGapis(ctx); err != nil {
+			return err
+		}
+		defer closeGapis(ctx, verb.gapis)
 	}
 
-	allocationFlags := []*service.Constant{}
-	if mem.AllocationFlagsIndex != -1 {
-		boxedConstants, err := client.Get(ctx, (&path.ConstantSet{
-			API:   mem.API,
-			Index: mem.AllocationFlagsIndex,
-		}).Path(), nil)
-		if err != nil {
-			return log.Errf(ctx, err, "Failed to load allocation flag names")
+	if verb.Compare.Files {
+		return verb.compareFiles(ctx, flags.Arg(0), flags.Arg(1), filter)
+	}
+
+	captureFiles := expandCaptureFiles(flags.Args())
+	if len(captureFiles) > 1 {
+		for _, captureFile := range captureFiles {
+			if captureFile == "-" {
+				app.Usage(ctx, "Can't read a capture from stdin along with other capture files")
+				return nil
+			}
 		}
-		constants := boxedConstants.(*service.ConstantSet)
-		// If not a bitfield, we can't compare it against the flags
-		if constants.IsBitfield {
-			allocationFlags = constants.Constants
+		return verb.runCaptures(ctx, captureFiles, filter)
+	}
+	return verb.runCapture(ctx, flags.Arg(0), filter)
+}
+
+// expandCaptureFiles returns the capture files matching each of the glob
+// patterns, for shells that don't expand them. Arguments that don't match any
+// file are kept, so they are reported when they fail to load.
+func expandCaptureFiles(args []string) []string {
+	files := []string{}
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil || len(matches) == 0 {
+			files = append(files, arg)
+			continue
 		}
+		files = append(files, matches...)
 	}
+	return files
+}
 
-	w := tabwriter.NewWriter(os.Stdout, 4, 4, 0, ' ', 0)
-	fmt.Fprintf(w, "%v memory allocations\n", len(mem.Allocations))
-	sort.Slice(mem.Allocations, func(i, j int) bool {
-		return mem.Allocations[i].Handle < mem.Allocations[j].Handle
-	})
+// runCaptures runs the verb on each of the capture files, printing the name of
+// each file before its output. A capture that fails is logged and skipped, and
+// the number of failed captures is printed at the end.
+func (verb *memoryVerb) runCaptures(ctx context.Context, captureFiles []string, filter allocationFilter) error {
+	failed := 0
+	limits, code := []string{}, memoryAliasExit
+	for i, captureFile := range captureFiles {
+		if i > 0 {
+			fmt.Fprintln(verb.out)
+		}
+		if task.Stopped(ctx) {
+			return cancelled(ctx)
+		}
+		fmt.Fprintf(verb.out, "==> %v <==\n", captureFile)
+		err := verb.runCapture(ctx, captureFile, filter)
+		if exit, ok := err.(app.ExitError); ok {
+			// The capture was loaded, but is over one of the limits.
+			limits = append(limits, fmt.Sprintf("%v: %v", captureFile, exit.Err))
+			if exit.Code == memoryBudgetExit {
+				code = memoryBudgetExit
+			}
+		} else if err != nil {
+			log.E(ctx, "Failed to get the memory breakdown of %v: %v", captureFile, err)
+			failed++
+		}
+	}
+	fmt.Fprintf(verb.out, "\n%v captures succeeded, %v failed\n", len(captureFiles)-failed, failed)
 
-	for _, alloc := range mem.Allocations {
-		fmt.Fprintln(w, "Name:", alloc.Name)
-		fmt.Fprintf(w, "\tDevice: \t%v\n", alloc.Device)
-		fmt.Fprintf(w, "\tMemory Type: \t%v\n", alloc.MemoryType)
-		fmt.Fprintf(w, "\tSize: \t%v\n", alloc.Size)
-
-		if alloc.Flags != 0 && len(allocationFlags) != 0 {
-			fmt.Fprintln(w, "\tFlags:")
-			for _, f := range allocationFlags {
-				if (alloc.Flags & uint32(f.Value)) != 0 {
-					fmt.Fprintf(w, "\t\t%v\n", f.Name)
-				}
+	if failed > 0 {
+		return log.Errf(ctx, nil, "%v of %v captures failed", failed, len(captureFiles))
+	}
+	if len(limits) > 0 {
+		return app.ExitError{Code: code, Err: errors.New(strings.Join(limits, "\n"))}
+	}
+	return nil
+}
+
+// runCapture prints the memory breakdown of a single capture file, or of the
+// -metrics-file.
+func (verb *memoryVerb) runCapture(ctx context.Context, captureFile string, filter allocationFilter) error {
+	// A capture streamed on stdin is handled like any other capture file, so
+	// the -at defaults still apply.
+	if captureFile == "-" && !verb.CaptureID && verb.Metrics.File == "" {
+		file, cleanup, err := stdinCapture(ctx)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		captureFile = file
+	}
+
+	if verb.Dump.Raw.Metrics {
+		return verb.dumpRawMetrics(ctx, captureFile)
+	}
+
+	if verb.Follow.Resource != "" {
+		return verb.followResource(ctx, captureFile, filter)
+	}
+
+	if verb.Watch > 0 || verb.Peak || verb.EachFrame {
+		return verb.scanMemory(ctx, captureFile, filter)
+	}
+
+	var snapshots []memorySnapshot
+	var allocationFlags *service.ConstantSet
+	var err error
+	if verb.Metrics.File != "" {
+		snapshots, err = verb.loadMetricsFile(ctx)
+	} else {
+		snapshots, allocationFlags, err = verb.getSnapshots(ctx, captureFile)
+	}
+	if err != nil {
+		return err
+	}
+	if verb.List.Devices {
+		// The devices are listed before filtering, so they can then be
+		// selected with -device.
+		verb.printDevices(snapshots)
+		return nil
+	}
+
+	if verb.Export.Bindings != "" {
+		// The breakdown is exported before filtering, so it is lossless.
+		return verb.exportBindings(ctx, snapshots[0])
+	}
+
+	if verb.Baseline.Save != "" {
+		// Like -export-bindings, so the baseline can be filtered when loaded.
+		return verb.saveBaseline(ctx, snapshots[0])
+	}
+
+	for _, snapshot := range snapshots {
+		snapshot.mem.Allocations = filter.apply(snapshot.mem.Allocations)
+	}
+	// The limits apply to all the allocations kept by the filter, not only the
+	// ones printed with -top.
+	failures := verb.checkLimits(snapshots)
+	assertions := verb.checkAssertions(snapshots)
+
+	for _, snapshot := range snapshots {
+		mem := snapshot.mem
+		verb.sortAllocations(mem.Allocations)
+		// With -leaks, -top applies to the leaks rather than to the snapshots.
+		if verb.Top > 0 && !verb.Leaks {
+			mem.Allocations = largestAllocations(mem.Allocations, verb.Top)
+			if verb.Sort != "" {
+				verb.sortAllocations(mem.Allocations)
 			}
 		}
+	}
+	// -offset and -limit page through the allocations left by -top, in the
+	// order they are printed.
+	verb.allocationCounts = make([]int, len(snapshots))
+	for i, snapshot := range snapshots {
+		verb.allocationCounts[i] = len(snapshot.mem.Allocations)
+		snapshot.mem.Allocations = pageAllocations(snapshot.mem.Allocations, verb.Offset, verb.Limit)
+	}
+
+	if err := verb.printSnapshots(ctx, snapshots, allocationFlags, filter); err != nil {
+		return err
+	}
+	if !verb.Json && !verb.JsonStream && !verb.Csv && !verb.Dot && verb.Format == "" {
+		verb.printAssertions(assertions)
+	}
+	return reportLimits(append(failures, assertionFailures(assertions)...))
+}
+
+// printSnapshots prints the memory breakdowns in the format selected by the
+// flags.
+func (verb *memoryVerb) printSnapshots(ctx context.Context, snapshots []memorySnapshot, allocationFlags *service.ConstantSet, filter allocationFilter) error {
+	if verb.Leaks {
+		return verb.printMemoryLeaks(ctx, snapshots[0], snapshots[1])
+	}
+
+	if verb.Unused {
+		verb.printUnusedMemory(snapshots)
+		return nil
+	}
+
+	if verb.Validate {
+		return verb.validateBindings(ctx, snapshots)
+	}
+
+	if verb.Baseline.Load != "" {
+		return verb.compareBaseline(ctx, snapshots[0], filter)
+	}
+
+	if verb.Find.Resource != "" {
+		return verb.printResourceBindings(snapshots)
+	}
+
+	if verb.Layers != "" {
+		return verb.printImageLayers(snapshots)
+	}
+
+	if verb.Treemap != "" {
+		return verb.writeMemoryTreemap(ctx, snapshots[0].mem, allocationFlags, filter)
+	}
+
+	if verb.Page.Size > 0 {
+		return verb.printMisalignedBindings(snapshots, filter)
+	}
 
-		if alloc.Mapping.Size != 0 {
-			fmt.Fprintf(w, "\tMapped into host memory at 0x%x\n",
-				alloc.Mapping.MappedAddress)
-			fmt.Fprintf(w, "\t\tOffset: \t%v\n", alloc.Mapping.Offset)
-			fmt.Fprintf(w, "\t\tSize: \t%v\n", alloc.Mapping.Size)
-		}
-
-		bindings := bindingSlice(alloc.Bindings)
-		sort.Slice(bindings, bindings.bindingLess)
-		fmt.Fprintf(w, "\t%v bindings:\n", len(bindings))
-		for _, binding := range bindings {
-			var typ string
-			switch binding.Type.(type) {
-			case *api.MemoryBinding_Buffer:
-				typ = "Buffer"
-			case *api.MemoryBinding_Image:
-				typ = "Image"
-			case *api.MemoryBinding_SparseImageBlock:
-				typ = "Sparse Image Block"
-			case *api.MemoryBinding_SparseImageMetadata:
-				typ = "Sparse Image Metadata"
-			case *api.MemoryBinding_SparseImageMipTail:
-				typ = "Sparse Image Mip Tail"
-			case *api.MemoryBinding_SparseOpaqueImageBlock:
-				typ = "Sparse Opaque Image Block"
-			case *api.MemoryBinding_SparseBufferBlock:
-				typ = "Sparse Buffer Block"
+	if verb.Diff || len(verb.Since) != 0 {
+		verb.printMemoryDiff(snapshots[0], snapshots[1])
+		return nil
+	}
+
+	if verb.Csv {
+		return verb.printMemoryCSV(ctx, snapshots, filter)
+	}
+
+	if verb.JsonStream {
+		return verb.printMemoryJSONStream(ctx, snapshots, allocationFlags, filter)
+	}
+
+	if verb.Dot {
+		return verb.printMemoryDOT(ctx, snapshots[0].mem, allocationFlags, filter)
+	}
+
+	if verb.Format != "" {
+		tmpl, err := verb.formatTemplate()
+		if err != nil {
+			return log.Err(ctx, err, "Invalid -format template")
+		}
+		for _, snapshot := range snapshots {
+			if err := verb.printMemoryFormat(ctx, verb.out, tmpl, snapshot, filter); err != nil {
+				return err
 			}
-			fmt.Fprintf(w, "\t%v: %v\n", typ, binding.Name)
-
-			fmt.Fprintf(w, "\t\tOffset: \t%v\n", binding.Offset)
-			fmt.Fprintf(w, "\t\tSize: \t%v\n", binding.Size)
-
-			switch val := binding.Type.(type) {
-			case *api.MemoryBinding_SparseImageBlock:
-				info := val.SparseImageBlock
-				fmt.Fprintf(w, "\t\tBlock Offset: \t(%v, %v)\n",
-					info.XOffset, info.YOffset)
-				fmt.Fprintf(w, "\t\tBlock Extent: \t(%v, %v)\n",
-					info.Width, info.Height)
-				fmt.Fprintf(w, "\t\tMip Level: \t%v\n", info.MipLevel)
-				fmt.Fprintf(w, "\t\tArray Layer: \t%v\n", info.ArrayLayer)
-				fmt.Fprintf(w, "\t\tAspects: \t%v\n", strings.Trim(fmt.Sprint(info.Aspects), "[]"))
-			case *api.MemoryBinding_SparseImageMetadata:
-				info := val.SparseImageMetadata
-				fmt.Fprintf(w, "\t\tArray Layer: \t%v\n", info.ArrayLayer)
-				fmt.Fprintf(w, "\t\tMip Tail Offset: \t%v\n", info.Offset)
-			case *api.MemoryBinding_SparseImageMipTail:
-				info := val.SparseImageMipTail
-				fmt.Fprintf(w, "\t\tArray Layer: \t%v\n", info.ArrayLayer)
-				fmt.Fprintf(w, "\t\tMip Tail Offset: \t%v\n", info.Offset)
-				fmt.Fprintf(w, "\t\tAspects: \t%v\n", strings.Trim(fmt.Sprint(info.Aspects), "[]"))
-			case *api.MemoryBinding_SparseOpaqueImageBlock:
-				fmt.Fprintf(w, "\t\tImage Memory Offset: \t%v\n",
-					val.SparseOpaqueImageBlock.Offset)
-			case *api.MemoryBinding_SparseBufferBlock:
-				fmt.Fprintf(w, "\t\tBuffer Memory Offset: \t%v\n",
-					val.SparseBufferBlock.Offset)
+		}
+		return nil
+	}
+
+	for i, snapshot := range snapshots {
+		if verb.Json {
+			if err := verb.printMemoryJSON(ctx, snapshot.cmd, snapshot.mem, allocationFlags, filter); err != nil {
+				return err
 			}
+			continue
 		}
 
-		aliases := bindings.computeAliasing()
-		if len(aliases) == 0 {
-			fmt.Fprintln(w, "\tNo aliased regions")
-		} else {
-			fmt.Fprintf(w, "\t%v aliased regions:\n", len(aliases))
-			for i, a := range aliases {
-				fmt.Fprintf(w, "\t%v:\n", i)
-				fmt.Fprintf(w, "\t\tOffset: \t%v\n", a.offset)
-				fmt.Fprintf(w, "\t\tSize: \t%v\n", a.size)
-				fmt.Fprintf(w, "\t\tShared by:\n")
-				for _, s := range a.sharers {
-					fmt.Fprintf(w, "\t\t\t%v\n", s)
-				}
+		// Every section states the command it reflects, so that saved reports
+		// are unambiguous.
+		if i > 0 {
+			fmt.Fprintln(verb.out)
+		}
+		fmt.Fprintf(verb.out, "%v:\n", snapshot.header())
+		if verb.Limit > 0 || verb.Offset > 0 {
+			fmt.Fprintf(verb.out, "Showing allocations %v..%v of %v\n",
+				verb.Offset, verb.Offset+len(snapshot.mem.Allocations), verb.allocationCounts[i])
+		}
+		verb.printAPI(snapshot.mem)
+		if !verb.Summary.Only {
+			if verb.Top > 0 && !verb.Verbose {
+				verb.printMemoryCompact(snapshot.mem, filter)
+			} else {
+				verb.printMemory(snapshot.mem, allocationFlags, filter)
 			}
+			fmt.Fprintln(verb.out)
+		}
+		if verb.ResourceSummary {
+			verb.printResourceSummary(snapshot.mem, filter)
+			fmt.Fprintln(verb.out)
 		}
+		verb.printMemorySummary(snapshot.mem, filter)
 	}
-	w.Flush()
 	return nil
 }
 
-type bindingSlice []*api.MemoryBinding
+// memorySummary is the grand total of the allocations and bindings printed.
+type memorySummary struct {
+	allocations, bindings             int
+	allocated, mapped, bound, aliased uint64
+}
 
-func (bindings bindingSlice) bindingLess(i, j int) bool {
-	if bindings[i].Offset != bindings[j].Offset {
-		return bindings[i].Offset < bindings[j].Offset
+// summarizeMemory returns the totals of the allocations, and of their bindings
+// and aliased regions that pass the filter.
+func summarizeMemory(allocs []*api.MemoryAllocation, filter allocationFilter) memorySummary {
+	s := memorySummary{allocations: len(allocs)}
+	for _, alloc := range allocs {
+		s.allocated += alloc.Size
+		s.mapped += alloc.GetMapping().GetSize()
+		for _, b := range filter.bindings(alloc.Bindings) {
+			s.bindings++
+			s.bound += b.Size
+		}
+		aliases, _ := breakdown.AllocationOverlaps(alloc)
+		s.aliased += aliasedBytes(filter.aliases(aliases, alloc.Bindings))
 	}
-	if bindings[i].Size != bindings[j].Size {
-		return bindings[i].Size < bindings[j].Size
+	return s
+}
+
+// printMemorySummary prints the totals of the allocations as a footer.
+func (verb *memoryVerb) printMemorySummary(mem *api.MemoryBreakdown, filter allocationFilter) {
+	s := summarizeMemory(mem.Allocations, filter)
+	w := verb.tabWriter(0)
+	fmt.Fprintf(w, "Total allocations: \t%v\n", s.allocations)
+	fmt.Fprintf(w, "Total bindings: \t%v\n", s.bindings)
+	fmt.Fprintf(w, "Total allocated: \t%v\n", verb.bytes(s.allocated))
+	fmt.Fprintf(w, "Total mapped: \t%v\n", verb.bytes(s.mapped))
+	fmt.Fprintf(w, "Total bound: \t%v\n", verb.bytes(s.bound))
+	fmt.Fprintf(w, "Total aliased: \t%v\n", verb.bytes(s.aliased))
+	w.Flush()
+}
+
+// largestAllocations returns the n largest allocations, sorted by descending
+// size.
+func largestAllocations(allocs []*api.MemoryAllocation, n int) []*api.MemoryAllocation {
+	sort.SliceStable(allocs, func(i, j int) bool {
+		return allocs[i].Size > allocs[j].Size
+	})
+	if len(allocs) > n {
+		allocs = allocs[:n]
 	}
-	return bindings[i].Handle < bindings[j].Handle
+	return allocs
 }
 
-type alias struct {
-	offset uint64
-	size   uint64
+// pageAllocations returns the allocations left after skipping the first offset
+// ones, at most limit of them if limit isn't 0.
+func pageAllocations(allocs []*api.MemoryAllocation, offset, limit int) []*api.MemoryAllocation {
+	if offset > len(allocs) {
+		offset = len(allocs)
+	}
+	allocs = allocs[offset:]
+	if limit > 0 && len(allocs) > limit {
+		allocs = allocs[:limit]
+	}
+	return allocs
+}
 
-	sharers []uint64
+// memorySnapshot is the memory breakdown after a single command.
+type memorySnapshot struct {
+	cmd *path.Command
+	mem *api.MemoryBreakdown
+	// label is the -at label the command was resolved from, e.g. frame:3,
+	// or the -metrics-file the breakdown was loaded from.
+	label string
 }
 
-func (bindings bindingSlice) computeAliasing() []alias {
-	if len(bindings) == 0 {
-		return []alias{}
+// header returns the line introducing the report of the snapshot, stating
+// the command it reflects and its label, if any.
+func (s memorySnapshot) header() string {
+	if len(s.cmd.GetIndices()) == 0 {
+		return fmt.Sprintf("Memory from %v", s.label)
+	}
+	if s.label != "" {
+		return fmt.Sprintf("Memory at command %v (%v)", s.cmd.Indices, s.label)
 	}
-	startsAt := map[uint64][]uint64{}
-	endsAt := map[uint64][]uint64{}
-	pointSet := map[uint64]struct{}{}
+	return fmt.Sprintf("Memory at command %v", s.cmd.Indices)
+}
 
-	for _, b := range bindings {
-		start := b.Offset
-		end := start + b.Size
+// connectGapis connects to, or starts, the GAPIS server used for all the
+// captures, so that they are loaded over a single connection.
+func (verb *memoryVerb) connectGapis(ctx context.Context) error {
+	gapis, err := getGapis(ctx, verb.Gapis, GapirFlags{})
+	if err != nil {
+		return log.Err(ctx, err, "Failed to connect to the GAPIS server")
+	}
+	verb.gapis = gapis
+	return nil
+}
 
-		s, _ := startsAt[start]
-		startsAt[start] = append(s, b.Handle)
-		pointSet[start] = struct{}{}
+// getSnapshots loads the capture and fetches the memory breakdown for each of
+// the -at commands, along with the allocation flag names.
+func (verb *memoryVerb) getSnapshots(ctx context.Context, captureFile string) ([]memorySnapshot, *service.ConstantSet, error) {
+	client := verb.gapis
+	cmds, labels, err := verb.snapshotCommands(ctx, captureFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	snapshots := make([]memorySnapshot, len(cmds))
+	ctx = status.Start(ctx, "Fetching memory breakdowns")
+	defer status.Finish(ctx)
+	err = verb.fetchEach(ctx, client, cmds, func(i int, mem *api.MemoryBreakdown) {
+		snapshots[i] = memorySnapshot{cmds[i], mem, labels[i]}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	verb.traceDeviceID, verb.traceDeviceKnown = singleDevice(snapshots)
 
-		e, _ := endsAt[end]
-		endsAt[end] = append(e, b.Handle)
-		pointSet[end] = struct{}{}
+	// The allocation flags only depend on the API, so are the same for all
+	// the requested commands.
+	allocationFlags, err := verb.fetchAllocationFlags(ctx, client, snapshots[0].mem)
+	if err != nil {
+		return nil, nil, err
 	}
+	return snapshots, allocationFlags, nil
+}
 
-	points := make([]uint64, 0, len(pointSet))
-	for k := range pointSet {
-		points = append(points, k)
+// snapshotCommands loads the capture and returns the -since and -at commands
+// of the snapshots, checking they are in range, along with the labels they
+// were resolved from, empty for command indices.
+func (verb *memoryVerb) snapshotCommands(ctx context.Context, captureFile string) ([]*path.Command, []string, error) {
+	client := verb.gapis
+	capture, err := loadCapture(ctx, client, captureFile, verb.CaptureFileFlags)
+	if err != nil {
+		return nil, nil, err
 	}
-	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
 
-	aliases := []alias{}
-	active := map[uint64]struct{}{}
-	for i, p := range points[:len(points)-1] {
-		e, _ := endsAt[p]
-		for _, handle := range e {
-			delete(active, handle)
+	resolved, err := resolveCapture(ctx, client, capture)
+	if err != nil {
+		return nil, nil, err
+	}
+	numCommands := uint64(resolved.NumCommands)
+	verb.traceDevice = describeDevice(resolved.Device)
+	// The default depends on the capture, so isn't stored in verb.At, as
+	// multiple captures can be given.
+	at := verb.At
+	if len(at) == 0 {
+		at = []CommandPoint{{Label: "last"}}
+		if verb.Leaks {
+			at = []CommandPoint{{Label: "first"}, {Label: "last"}}
+		}
+	}
+	points := make([]flags.U64Slice, len(at))
+	labels := make([]string, len(at))
+	for i, p := range at {
+		labels[i] = p.Label
+		if points[i], err = resolveCommandPoint(ctx, client, capture, numCommands, p); err != nil {
+			return nil, nil, err
 		}
-		s, _ := startsAt[p]
-		for _, handle := range s {
-			active[handle] = struct{}{}
+	}
+	if len(verb.Since) != 0 {
+		points = append([]flags.U64Slice{verb.Since}, points...)
+		labels = append([]string{""}, labels...)
+	}
+	for _, at := range points {
+		if err := checkCommandIndex(ctx, at, numCommands); err != nil {
+			return nil, nil, err
 		}
+	}
 
-		if len(active) > 1 {
-			sharers := []uint64{}
-			for k := range active {
-				sharers = append(sharers, k)
-			}
-			sort.Slice(sharers, func(i, j int) bool { return sharers[i] < sharers[j] })
-			aliases = append(aliases, alias{
-				offset:  p,
-				size:    points[i+1] - p,
-				sharers: sharers,
-			})
+	cmds := make([]*path.Command, len(points))
+	for i, at := range points {
+		cmds[i] = capture.Command(at[0], at[1:]...)
+	}
+	return cmds, labels, nil
+}
+
+// dumpRawMetrics prints the api.Metrics of each of the -at commands in the
+// protobuf text format, exactly as returned by GAPIS, for debugging.
+func (verb *memoryVerb) dumpRawMetrics(ctx context.Context, captureFile string) error {
+	cmds, _, err := verb.snapshotCommands(ctx, captureFile)
+	if err != nil {
+		return err
+	}
+	for _, cmd := range cmds {
+		boxedVal, err := verb.gapis.Get(ctx, (&path.Metrics{
+			Command:         cmd,
+			MemoryBreakdown: true,
+			MemoryAliasing:  true,
+		}).Path(), nil)
+		if err != nil {
+			return log.Errf(ctx, err, "Failed to load metrics at command %v", cmd.Indices)
 		}
+		fmt.Fprintf(verb.out, "# Metrics at command %v\n", cmd.Indices)
+		if err := proto.MarshalText(verb.out, boxedVal.(*api.Metrics)); err != nil {
+			return log.Err(ctx, err, "Couldn't marshal the metrics to text")
+		}
+	}
+	return nil
+}
+
+// fetchAllocationFlags returns the constants of the allocation flags of mem,
+// or nil with -no-flag-names, skipping the request. As the flag names are only
+// cosmetic, failing to fetch them is logged, and the flags are then printed
+// in hex.
+func (verb *memoryVerb) fetchAllocationFlags(ctx context.Context, client service.Service, mem *api.MemoryBreakdown) (*service.ConstantSet, error) {
+	if verb.No.Flag.Names {
+		return nil, nil
+	}
+	if verb.Api != "" {
+		// Only the API of the constant set is overridden, not the breakdown.
+		mem = &api.MemoryBreakdown{API: apiPath(verb.Api), AllocationFlagsIndex: mem.AllocationFlagsIndex}
+	}
+	var allocationFlags *service.ConstantSet
+	err := cancellable(ctx, func() error {
+		var err error
+		allocationFlags, err = breakdown.FetchAllocationFlags(ctx, client, mem)
+		return err
+	})
+	if err != nil && !task.Stopped(ctx) {
+		log.W(ctx, "Printing the allocation flags in hex: %v", err)
+		return nil, nil
 	}
+	return allocationFlags, err
+}
 
-	return aliases
+// stdinCapture copies the capture read from stdin to a temporary file, as
+// GAPIS loads captures from a path. The returned function removes the file.
+func stdinCapture(ctx context.Context) (string, func(), error) {
+	f, err := ioutil.TempFile("", "gapit-memory-*.gfxtrace")
+	if err != nil {
+		return "", nil, log.Err(ctx, err, "Failed to create a temporary capture file")
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	_, err = io.Copy(f, os.Stdin)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, log.Err(ctx, err, "Failed to read the capture from stdin")
+	}
+	return f.Name(), cleanup, nil
+}
+
+// getNumCommands resolves the capture and returns its number of commands. The
+// capture is resolved once per verb, as each request has a round-trip to GAPIS.
+func getNumCommands(ctx context.Context, client service.Service, capture *path.Capture) (uint64, error) {
+	c, err := resolveCapture(ctx, client, capture)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(c.NumCommands), nil
+}
+
+// resolveCapture resolves the capture, for its number of commands and the
+// device it was traced on.
+func resolveCapture(ctx context.Context, client service.Service, capture *path.Capture) (*service.Capture, error) {
+	var boxedCapture interface{}
+	err := cancellable(ctx, func() error {
+		var err error
+		boxedCapture, err = client.Get(ctx, capture.Path(), nil)
+		return err
+	})
+	if err != nil {
+		return nil, log.Err(ctx, err, "Failed to load the capture")
+	}
+	return boxedCapture.(*service.Capture), nil
+}
+
+// checkCommandIndex returns an error if the command/subcommand index at does
+// not refer to one of the numCommands commands of the capture.
+func checkCommandIndex(ctx context.Context, at []uint64, numCommands uint64) error {
+	if len(at) == 0 {
+		return log.Err(ctx, nil, "Empty command index")
+	}
+	if at[0] >= numCommands {
+		return log.Errf(ctx, nil, "Command index %v out of range (capture has %v commands)", at[0], numCommands)
+	}
+	return nil
+}
+
+// loadMetricsFile loads the memory breakdown from the binary api.Metrics
+// protobuf given with -metrics-file, without connecting to GAPIS. As the
+// allocation flag names can't be resolved offline, they are not printed.
+func (verb *memoryVerb) loadMetricsFile(ctx context.Context) ([]memorySnapshot, error) {
+	data, err := ioutil.ReadFile(verb.Metrics.File)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Failed to read metrics file %v", verb.Metrics.File)
+	}
+	mem, err := breakdown.Unmarshal(data)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Failed to decode metrics file %v", verb.Metrics.File)
+	}
+	return []memorySnapshot{{&path.Command{}, mem, verb.Metrics.File}}, nil
+}
+
+// exportBindings writes the unfiltered memory breakdown of the snapshot to the
+// -export-bindings file, as a binary api.Metrics protobuf that can be read back
+// with -metrics-file.
+func (verb *memoryVerb) exportBindings(ctx context.Context, snapshot memorySnapshot) error {
+	data, err := breakdown.Marshal(snapshot.mem)
+	if err != nil {
+		return log.Err(ctx, err, "Couldn't encode the memory breakdown")
+	}
+	if err := ioutil.WriteFile(verb.Export.Bindings, data, 0644); err != nil {
+		return log.Errf(ctx, err, "Failed to write %v", verb.Export.Bindings)
+	}
+	log.I(ctx, "Exported the memory breakdown at command %v to %v", snapshot.cmd.Indices, verb.Export.Bindings)
+	return nil
+}
+
+// allocationFilter selects which allocations are printed.
+type allocationFilter struct {
+	devices     map[uint64]struct{}
+	memoryTypes map[uint64]struct{}
+	minSize     uint64
+
+	minBindingSize uint64
+
+	// resources are the handles of the bound resources to focus on.
+	resources map[uint64]struct{}
+
+	// bindingTypes are the names of the binding types to keep, see
+	// bindingTypeName. With typeAliases, they also filter the aliased regions.
+	bindingTypes map[string]struct{}
+	typeAliases  bool
+
+	// includeName and excludeName match the names of the allocations to keep
+	// and to drop, nil to keep all of them.
+	includeName *regexp.Regexp
+	excludeName *regexp.Regexp
+}
+
+func (verb *memoryVerb) allocationFilter() (allocationFilter, error) {
+	devices, err := parseU64Set(verb.Device)
+	if err != nil {
+		return allocationFilter{}, fmt.Errorf("Invalid device %v", err)
+	}
+	memoryTypes, err := parseU64Set(verb.Memory.Type)
+	if err != nil {
+		return allocationFilter{}, fmt.Errorf("Invalid memory type %v", err)
+	}
+	resources, err := parseU64Set(verb.Resource)
+	if err != nil {
+		return allocationFilter{}, fmt.Errorf("Invalid resource %v", err)
+	}
+	bindingTypes, err := parseBindingTypes(verb.Binding.Type)
+	if err != nil {
+		return allocationFilter{}, err
+	}
+	includeName, err := compileNameFilter("-include-name", verb.Include.Name)
+	if err != nil {
+		return allocationFilter{}, err
+	}
+	excludeName, err := compileNameFilter("-exclude-name", verb.Exclude.Name)
+	if err != nil {
+		return allocationFilter{}, err
+	}
+	return allocationFilter{
+		devices:        devices,
+		memoryTypes:    memoryTypes,
+		minSize:        uint64(verb.Min.Size),
+		minBindingSize: uint64(verb.Min.Binding.Size),
+		resources:      resources,
+		bindingTypes:   bindingTypes,
+		typeAliases:    verb.Binding.TypeAliases,
+		includeName:    includeName,
+		excludeName:    excludeName,
+	}, nil
+}
+
+// compileNameFilter compiles the regular expression of the name filter flag,
+// returning nil if it is empty.
+func compileNameFilter(name, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %v regular expression %q: %v", name, expr, err)
+	}
+	return re, nil
+}
+
+// bindingTypes are the names of the binding types accepted by -binding-type.
+var bindingTypes = []string{
+	"buffer",
+	"image",
+	"sparse-image-block",
+	"sparse-image-metadata",
+	"sparse-image-mip-tail",
+	"sparse-opaque-image-block",
+	"sparse-buffer-block",
+}
+
+// bindingTypeName returns the name of the type of the binding used by
+// -binding-type, e.g. sparse-image-block.
+func bindingTypeName(b *api.MemoryBinding) string {
+	return strings.ToLower(strings.Replace(breakdown.BindingTypeName(b), " ", "-", -1))
+}
+
+// parseBindingTypes parses a comma separated list of binding type names. An
+// empty string results in an empty set.
+func parseBindingTypes(s string) (map[string]struct{}, error) {
+	out := map[string]struct{}{}
+	if s == "" {
+		return out, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		known := false
+		for _, t := range bindingTypes {
+			known = known || t == name
+		}
+		if !known {
+			return nil, fmt.Errorf("Invalid binding type %q, expected one of %v", name, strings.Join(bindingTypes, ", "))
+		}
+		out[name] = struct{}{}
+	}
+	return out, nil
+}
+
+// keepBindingType returns whether the type of the binding passes the filter.
+func (f allocationFilter) keepBindingType(b *api.MemoryBinding) bool {
+	if len(f.bindingTypes) == 0 {
+		return true
+	}
+	_, ok := f.bindingTypes[bindingTypeName(b)]
+	return ok
+}
+
+func (f allocationFilter) keep(alloc *api.MemoryAllocation) bool {
+	if alloc.Size < f.minSize {
+		return false
+	}
+	if f.includeName != nil && !f.includeName.MatchString(alloc.Name) {
+		return false
+	}
+	if f.excludeName != nil && f.excludeName.MatchString(alloc.Name) {
+		return false
+	}
+	if len(f.devices) > 0 {
+		if _, ok := f.devices[alloc.Device]; !ok {
+			return false
+		}
+	}
+	if len(f.memoryTypes) > 0 {
+		if _, ok := f.memoryTypes[uint64(alloc.MemoryType)]; !ok {
+			return false
+		}
+	}
+	if len(f.resources) > 0 {
+		for _, b := range alloc.Bindings {
+			if _, ok := f.resources[b.Handle]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// apply returns the allocations that pass the filter.
+func (f allocationFilter) apply(allocs []*api.MemoryAllocation) []*api.MemoryAllocation {
+	out := make([]*api.MemoryAllocation, 0, len(allocs))
+	for _, alloc := range allocs {
+		if f.keep(alloc) {
+			out = append(out, alloc)
+		}
+	}
+	return out
+}
+
+// bindings returns the bindings that pass the filter. Filtered bindings are
+// still considered when computing the aliasing of an allocation.
+func (f allocationFilter) bindings(bindings breakdown.Bindings) breakdown.Bindings {
+	out := make(breakdown.Bindings, 0, len(bindings))
+	for _, b := range bindings {
+		if b.Size < f.minBindingSize || !f.keepBindingType(b) {
+			continue
+		}
+		if _, ok := f.resources[b.Handle]; ok || len(f.resources) == 0 {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// hiddenBindings describes the bindings of listed that the filter hides, given
+// the number shown, or returns an empty string if none are. The bindings under
+// -min-binding-size are counted apart from those hidden by the other filters.
+func (f allocationFilter) hiddenBindings(listed breakdown.Bindings, shown int) string {
+	hidden, smaller := len(listed)-shown, 0
+	if hidden == 0 {
+		return ""
+	}
+	for _, b := range listed {
+		if b.Size < f.minBindingSize {
+			smaller++
+		}
+	}
+	switch smaller {
+	case hidden:
+		return fmt.Sprintf("%v smaller bindings hidden", smaller)
+	case 0:
+		return fmt.Sprintf("%v bindings hidden by filters", hidden)
+	default:
+		return fmt.Sprintf("%v smaller bindings and %v more hidden by filters", smaller, hidden-smaller)
+	}
+}
+
+// aliases returns the aliased regions of the bindings shared by the resources
+// given with -resource, or all the regions without -resource. With
+// -binding-type-aliases, the regions must also be shared by a binding of the
+// types given with -binding-type.
+func (f allocationFilter) aliases(regions []breakdown.Alias, bindings breakdown.Bindings) []breakdown.Alias {
+	byType := f.typeAliases && len(f.bindingTypes) > 0
+	if len(f.resources) == 0 && !byType {
+		return regions
+	}
+	typed := map[uint64]struct{}{}
+	if byType {
+		for _, b := range bindings {
+			if f.keepBindingType(b) {
+				typed[b.Handle] = struct{}{}
+			}
+		}
+	}
+	out := []breakdown.Alias{}
+	for _, a := range regions {
+		resource, ofType := len(f.resources) == 0, !byType
+		for _, s := range a.Sharers {
+			if _, ok := f.resources[s]; ok {
+				resource = true
+			}
+			if _, ok := typed[s]; ok {
+				ofType = true
+			}
+		}
+		if resource && ofType {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// aliasPairs returns the pairs of bindings of which one is of the resources
+// given with -resource, or all the pairs without -resource.
+func (f allocationFilter) aliasPairs(pairs []breakdown.AliasPair) []breakdown.AliasPair {
+	if len(f.resources) == 0 {
+		return pairs
+	}
+	out := []breakdown.AliasPair{}
+	for _, p := range pairs {
+		_, first := f.resources[p.First.Handle]
+		_, second := f.resources[p.Second.Handle]
+		if first || second {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// parseU64Set parses a comma separated list of integers. An empty string
+// results in an empty set.
+func parseU64Set(s string) (map[uint64]struct{}, error) {
+	set := map[uint64]struct{}{}
+	if s == "" {
+		return set, nil
+	}
+	for _, v := range strings.Split(s, ",") {
+		i, err := strconv.ParseUint(strings.TrimSpace(v), 0, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q", v)
+		}
+		set[i] = struct{}{}
+	}
+	return set, nil
+}
+
+func (verb *memoryVerb) printMemory(mem *api.MemoryBreakdown, allocationFlags *service.ConstantSet, filter allocationFilter) {
+	w := verb.tabWriter(0)
+	fmt.Fprintf(w, "%v memory allocations\n", len(mem.Allocations))
+
+	if verb.Group.By.Type {
+		total := uint64(0)
+		for _, group := range groupByMemoryType(mem.Allocations) {
+			fmt.Fprintf(w, "Memory Type %v: %v allocations\n", group.memoryType, len(group.allocations))
+			subtotal := uint64(0)
+			for _, alloc := range group.allocations {
+				verb.printAllocation(w, alloc, allocationFlags, filter)
+				subtotal += alloc.Size
+			}
+			fmt.Fprintf(w, "Subtotal: \t%v\n", verb.bytes(subtotal))
+			total += subtotal
+		}
+		fmt.Fprintf(w, "Total: \t%v\n", verb.bytes(total))
+	} else {
+		for _, alloc := range mem.Allocations {
+			verb.printAllocation(w, alloc, allocationFlags, filter)
+		}
+	}
+	w.Flush()
+
+	if verb.Sparse.Grid {
+		fmt.Fprintln(verb.out)
+		verb.printSparseGrids(verb.out, mem.Allocations)
+	}
+}
+
+// memoryTypeGroup is the list of allocations from a single memory type.
+type memoryTypeGroup struct {
+	memoryType  uint32
+	allocations []*api.MemoryAllocation
+}
+
+// deviceSummary is the number and total size of the allocations on a device.
+type deviceSummary struct {
+	device uint64
+	count  int
+	size   uint64
+}
+
+// summarizeDevices returns the summary of the allocations of each device, in
+// order of increasing device.
+func summarizeDevices(allocs []*api.MemoryAllocation) []deviceSummary {
+	devices := map[uint64]*deviceSummary{}
+	for _, alloc := range allocs {
+		d, ok := devices[alloc.Device]
+		if !ok {
+			d = &deviceSummary{device: alloc.Device}
+			devices[alloc.Device] = d
+		}
+		d.count++
+		d.size += alloc.Size
+	}
+	out := make([]deviceSummary, 0, len(devices))
+	for _, d := range devices {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].device < out[j].device })
+	return out
+}
+
+// printDevices prints the devices the allocations of the snapshots are on,
+// with the number and total size of their allocations.
+func (verb *memoryVerb) printDevices(snapshots []memorySnapshot) {
+	for i, snapshot := range snapshots {
+		if len(snapshots) > 1 {
+			if i > 0 {
+				fmt.Fprintln(verb.out)
+			}
+			fmt.Fprintf(verb.out, "%v:\n", snapshot.header())
+		}
+		w := verb.tabWriter(2)
+		fmt.Fprintln(w, "device\tallocations\tsize")
+		for _, d := range summarizeDevices(snapshot.mem.Allocations) {
+			fmt.Fprintf(w, "%v\t%v\t%v\n", verb.deviceName(d.device), d.count, verb.bytes(d.size))
+		}
+		w.Flush()
+	}
+}
+
+// groupByMemoryType groups the allocations by their memory type, in order of
+// increasing memory type. The order of the allocations within a group is
+// preserved.
+func groupByMemoryType(allocs []*api.MemoryAllocation) []memoryTypeGroup {
+	groups := map[uint32]*memoryTypeGroup{}
+	for _, alloc := range allocs {
+		g, ok := groups[alloc.MemoryType]
+		if !ok {
+			g = &memoryTypeGroup{memoryType: alloc.MemoryType}
+			groups[alloc.MemoryType] = g
+		}
+		g.allocations = append(g.allocations, alloc)
+	}
+	out := make([]memoryTypeGroup, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].memoryType < out[j].memoryType })
+	return out
+}
+
+// printAllocation prints the details, bindings and aliased regions of a single
+// allocation.
+func (verb *memoryVerb) printAllocation(w io.Writer, alloc *api.MemoryAllocation, allocationFlags *service.ConstantSet, filter allocationFilter) {
+	if verb.Highlight.Size > 0 && alloc.Size >= uint64(verb.Highlight.Size) {
+		fmt.Fprintln(w, verb.colorize(colorYellow, "Name: "+alloc.Name))
+	} else {
+		fmt.Fprintln(w, "Name:", alloc.Name)
+	}
+	if verb.Alloc.ID {
+		fmt.Fprintf(w, "\tID: \t%v\n", allocationID(alloc))
+	}
+	if verb.Hex.Handles || verb.verbose() {
+		fmt.Fprintf(w, "\tHandle: \t%v\n", verb.handle(alloc.Handle))
+	}
+	fmt.Fprintf(w, "\tDevice: \t%v\n", verb.deviceName(alloc.Device))
+	flagNames := verb.flagNameCache(allocationFlags)
+	fmt.Fprintf(w, "\tMemory Type: \t%v\n", memoryTypeName(alloc, flagNames))
+	if verb.Show.Heap.Usage && alloc.HeapSize != 0 {
+		fmt.Fprintf(w, "\tSize: \t%v (%.1f%% of heap %v)\n",
+			verb.bytes(alloc.Size), percent(alloc.Size, alloc.HeapSize), alloc.Heap)
+	} else {
+		fmt.Fprintf(w, "\tSize: \t%v\n", verb.bytes(alloc.Size))
+	}
+
+	// The flag names are printed with the memory type, only the raw value is
+	// printed here, if the names are unknown or with -verbose.
+	if alloc.Flags != 0 && (allocationFlags == nil || verb.verbose()) {
+		fmt.Fprintf(w, "\tFlags: \t0x%x\n", alloc.Flags)
+	}
+
+	if alloc.Mapping.Size != 0 {
+		coherency := ""
+		if coherent, known := isHostCoherent(alloc, allocationFlags); known && !coherent {
+			coherency = " (non-coherent: needs flush/invalidate)"
+		}
+		fmt.Fprintf(w, "\tMapped into host memory at 0x%x%v\n",
+			alloc.Mapping.MappedAddress, coherency)
+		fmt.Fprintf(w, "\t\tOffset: \t%v\n", alloc.Mapping.Offset)
+		fmt.Fprintf(w, "\t\tSize: \t%v\n", verb.bytes(alloc.Mapping.Size))
+	}
+
+	if verb.No.Bindings {
+		if verb.Compact.Aliases {
+			bindings := breakdown.Bindings(alloc.Bindings)
+			aliases, _ := breakdown.AllocationOverlaps(alloc)
+			verb.printCompactAliases(w, bindings, filter.aliases(aliases, bindings))
+		}
+		return
+	}
+
+	bindings := breakdown.Bindings(alloc.Bindings)
+	sort.Slice(bindings, bindings.Less)
+	listed, merged := bindings, map[*api.MemoryBinding]int{}
+	if verb.Coalesce {
+		listed, merged = bindings.Coalesce()
+	}
+	shown := verb.sortBindings(filter.bindings(listed))
+	if hidden := filter.hiddenBindings(listed, len(shown)); hidden != "" {
+		fmt.Fprintf(w, "\t%v bindings (%v):\n", len(shown), hidden)
+	} else if len(shown) != 0 || !verb.Quiet {
+		fmt.Fprintf(w, "\t%v bindings:\n", len(shown))
+	}
+	if verb.Histogram {
+		verb.printBindingHistogram(w, shown)
+	} else {
+		verb.printBindings(w, shown, merged)
+	}
+
+	aliases, overlaps := breakdown.AllocationOverlaps(alloc)
+	aliases, overlaps = filter.aliases(aliases, bindings), filter.aliases(overlaps, bindings)
+	sortBySeverity(bindings, aliases)
+	names := bindings.Names()
+	if verb.Compact.Aliases {
+		verb.printCompactAliases(w, bindings, aliases)
+	} else if len(aliases) == 0 {
+		if !verb.Quiet {
+			fmt.Fprintln(w, "\tNo aliased regions")
+		}
+	} else {
+		fmt.Fprintf(w, "\t%v\n", verb.colorize(colorRed, fmt.Sprintf("%v aliased regions:", len(aliases))))
+		for i, a := range aliases {
+			fmt.Fprintf(w, "\t%v\n", verb.colorize(colorRed, fmt.Sprintf("%v: (%v, %v severity)", i, a.Kind(), bindings.AliasSeverity(a))))
+			fmt.Fprintf(w, "\t\tOffset: \t%v\n", a.Offset)
+			fmt.Fprintf(w, "\t\tSize: \t%v\n", verb.bytes(a.Size))
+			fmt.Fprintf(w, "\t\tShared by:\n")
+			for _, s := range verb.sortSharers(a.Sharers, names) {
+				fmt.Fprintf(w, "\t\t\t%v\n", verb.sharerName(s, names))
+			}
+		}
+		total := aliasedBytes(aliases)
+		fmt.Fprintf(w, "\tTotal aliased: \t%v (%.1f%% of allocation)\n",
+			verb.bytes(total), percent(total, alloc.Size))
+	}
+	if len(overlaps) != 0 {
+		fmt.Fprintf(w, "\t%v non-conflicting overlaps:\n", len(overlaps))
+		for i, o := range overlaps {
+			fmt.Fprintf(w, "\t%v:\n", i)
+			fmt.Fprintf(w, "\t\tOffset: \t%v\n", o.Offset)
+			fmt.Fprintf(w, "\t\tSize: \t%v\n", verb.bytes(o.Size))
+			fmt.Fprintf(w, "\t\tShared by:\n")
+			for _, s := range verb.sortSharers(o.Sharers, names) {
+				fmt.Fprintf(w, "\t\t\t%v\n", verb.sharerName(s, names))
+			}
+		}
+	}
+
+	if conflicts := filter.aliasPairs(bindings.OpaqueBlockOverlaps()); len(conflicts) != 0 {
+		fmt.Fprintf(w, "\t%v\n", verb.colorize(colorRed, fmt.Sprintf(
+			"Warning: %v regions bound by both sparse opaque and sparse image blocks of the same image:", len(conflicts))))
+		for _, p := range conflicts {
+			fmt.Fprintf(w, "\t%v: \t[%v, %v)\n", verb.sharerName(p.First.Handle, names), p.Start, p.End)
+		}
+	}
+
+	if verb.Alias.Pairs {
+		pairs := bindings.ComputeAliasPairs()
+		if len(pairs) != 0 || !verb.Quiet {
+			fmt.Fprintf(w, "\t%v aliased pairs:\n", len(pairs))
+		}
+		for _, p := range pairs {
+			fmt.Fprintf(w, "\t%v and %v: \t[%v, %v)\n",
+				verb.sharerName(p.First.Handle, names), verb.sharerName(p.Second.Handle, names), p.Start, p.End)
+		}
+	}
+
+	unbound := bindings.Unbound(alloc.Size)
+	fmt.Fprintf(w, "\tUnbound: \t%v (%.1f%%)\n", verb.bytes(unbound), percent(unbound, alloc.Size))
+	if verb.Fragmentation {
+		fmt.Fprintf(w, "\tFragmentation: \t%.1f%%\n",
+			percent(bindings.Fragmentation(), alloc.Size))
+	}
+}
+
+// printBindings prints the details of each of the bindings. merged is the
+// number of bindings each binding was coalesced from with -coalesce.
+func (verb *memoryVerb) printBindings(w io.Writer, bindings breakdown.Bindings, merged map[*api.MemoryBinding]int) {
+	for _, binding := range bindings {
+		if n := merged[binding]; n > 1 {
+			fmt.Fprintf(w, "\t%v: %v (merged from %v)\n", breakdown.BindingTypeName(binding), binding.Name, n)
+		} else {
+			fmt.Fprintf(w, "\t%v: %v\n", breakdown.BindingTypeName(binding), binding.Name)
+		}
+
+		if verb.Hex.Handles {
+			fmt.Fprintf(w, "\t\tHandle: \t%v\n", verb.handle(binding.Handle))
+		}
+		fmt.Fprintf(w, "\t\tOffset: \t%v\n", binding.Offset)
+		fmt.Fprintf(w, "\t\tSize: \t%v\n", verb.bytes(binding.Size))
+		if merged[binding] > 1 {
+			// The sparse binding details only describe a single binding.
+			continue
+		}
+
+		switch val := binding.Type.(type) {
+		case *api.MemoryBinding_SparseImageBlock:
+			info := val.SparseImageBlock
+			fmt.Fprintf(w, "\t\tBlock Offset: \t(%v, %v)\n",
+				info.XOffset, info.YOffset)
+			fmt.Fprintf(w, "\t\tBlock Extent: \t(%v, %v)\n",
+				info.Width, info.Height)
+			fmt.Fprintf(w, "\t\tMip Level: \t%v\n", info.MipLevel)
+			fmt.Fprintf(w, "\t\tArray Layer: \t%v\n", info.ArrayLayer)
+		case *api.MemoryBinding_SparseImageMetadata:
+			info := val.SparseImageMetadata
+			fmt.Fprintf(w, "\t\tArray Layer: \t%v\n", info.ArrayLayer)
+			fmt.Fprintf(w, "\t\tMip Tail Offset: \t%v\n", info.Offset)
+		case *api.MemoryBinding_SparseImageMipTail:
+			info := val.SparseImageMipTail
+			fmt.Fprintf(w, "\t\tArray Layer: \t%v\n", info.ArrayLayer)
+			fmt.Fprintf(w, "\t\tMip Tail Offset: \t%v\n", info.Offset)
+		case *api.MemoryBinding_SparseOpaqueImageBlock:
+			fmt.Fprintf(w, "\t\tImage Memory Offset: \t%v\n",
+				val.SparseOpaqueImageBlock.Offset)
+		case *api.MemoryBinding_SparseBufferBlock:
+			fmt.Fprintf(w, "\t\tBuffer Memory Offset: \t%v\n",
+				val.SparseBufferBlock.Offset)
+		}
+		if aspects := bindingAspects(binding); len(aspects) > 0 {
+			fmt.Fprintf(w, "\t\tAspects: \t%v\n", aspects)
+		}
+	}
+}
+
+// printCompactAliases prints the one line summary of the aliased regions of an
+// allocation printed with -compact-aliases: their count, total size and
+// highest severity.
+func (verb *memoryVerb) printCompactAliases(w io.Writer, bindings breakdown.Bindings, aliases []breakdown.Alias) {
+	if len(aliases) == 0 {
+		if !verb.Quiet {
+			fmt.Fprintln(w, "\tAliases: \tnone")
+		}
+		return
+	}
+	max := breakdown.SeverityNone
+	for _, a := range aliases {
+		if s := bindings.AliasSeverity(a); s > max {
+			max = s
+		}
+	}
+	fmt.Fprintf(w, "\tAliases: \t%v\n", verb.colorize(colorRed, fmt.Sprintf("%v regions, %v total, max severity %v",
+		len(aliases), verb.bytes(aliasedBytes(aliases)), strings.ToUpper(max.String()))))
+}
+
+// sortBySeverity sorts the aliased regions of the bindings by descending
+// severity, keeping regions of equal severity in order of offset.
+func sortBySeverity(bindings breakdown.Bindings, aliases []breakdown.Alias) {
+	sort.SliceStable(aliases, func(i, j int) bool {
+		return bindings.AliasSeverity(aliases[i]) > bindings.AliasSeverity(aliases[j])
+	})
+}
+
+// aliasedBytes returns the total size of the aliased regions.
+func aliasedBytes(aliases []breakdown.Alias) uint64 {
+	total := uint64(0)
+	for _, a := range aliases {
+		total += a.Size
+	}
+	return total
+}
+
+// percent returns n as a percentage of total.
+func percent(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// printMemoryCompact prints a single line summary per allocation.
+func (verb *memoryVerb) printMemoryCompact(mem *api.MemoryBreakdown, filter allocationFilter) {
+	w := verb.tabWriter(1)
+	fmt.Fprintf(w, "%v memory allocations\n", len(mem.Allocations))
+	fmt.Fprintln(w, "Name\tSize\tBindings")
+	for _, alloc := range mem.Allocations {
+		bindings := filter.bindings(alloc.Bindings)
+		fmt.Fprintf(w, "%v\t%v\t%v\n", alloc.Name, verb.bytes(alloc.Size), len(bindings))
+	}
+	w.Flush()
+}
+
+// verbose returns whether the extra details are printed, with -v or when
+// logging debug messages.
+func (verb *memoryVerb) verbose() bool {
+	return verb.V || app.Flags.Log.Level <= log.Debug
+}
+
+// tabWriter returns a writer aligning the columns of the text output, with the
+// -min-width and -column-padding cell sizes. The columns are separated by at
+// least a space, so that long object names don't run into the next column.
+func (verb *memoryVerb) tabWriter(padding int) *tabwriter.Writer {
+	minWidth := 4
+	if verb.Min.Width > 0 {
+		minWidth = verb.Min.Width
+	}
+	if verb.Column.Padding > 0 {
+		padding = verb.Column.Padding
+	} else if padding < 1 {
+		padding = 1
+	}
+	return tabwriter.NewWriter(verb.out, minWidth, 4, padding, ' ', 0)
+}
+
+// bytes formats a byte count for printing, honoring -raw-bytes.
+func (verb *memoryVerb) bytes(n uint64) string {
+	if verb.Raw.Bytes {
+		return fmt.Sprint(n)
+	}
+	return humanBytes(n)
+}
+
+// handle formats an allocation or resource handle, in hex with -hex-handles.
+func (verb *memoryVerb) handle(h uint64) string {
+	if verb.Hex.Handles {
+		return fmt.Sprintf("0x%x", h)
+	}
+	return fmt.Sprint(h)
+}
+
+// humanBytes formats a byte count using binary units, e.g. 1.5 KiB.
+func humanBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+type aspectList []api.AspectType
+
+func (l aspectList) names() []string {
+	names := make([]string, len(l))
+	for i, a := range l {
+		var typ string
+		switch a {
+		case api.AspectType_COLOR:
+			typ = "Color"
+		case api.AspectType_DEPTH:
+			typ = "Depth"
+		case api.AspectType_STENCIL:
+			typ = "Stencil"
+		default:
+			typ = fmt.Sprintf("Aspect(%d)", int32(a))
+		}
+		names[i] = typ
+	}
+	return names
+}
+
+// bindingAspects returns the aspects of the image the binding is from, for
+// the binding types that record them. Buffer, plain image and opaque sparse
+// bindings don't, as they bind all the aspects of the resource.
+func bindingAspects(b *api.MemoryBinding) aspectList {
+	switch val := b.Type.(type) {
+	case *api.MemoryBinding_SparseImageBlock:
+		return val.SparseImageBlock.Aspects
+	case *api.MemoryBinding_SparseImageMetadata:
+		return val.SparseImageMetadata.Aspects
+	case *api.MemoryBinding_SparseImageMipTail:
+		return val.SparseImageMipTail.Aspects
+	default:
+		return nil
+	}
+}
+
+// Format implements fmt.Formatter, printing the aspects as a comma separated
+// list.
+func (l aspectList) Format(f fmt.State, c rune) {
+	fmt.Fprint(f, strings.Join(l.names(), ", "))
+}
+
+// MarshalJSON implements json.Marshaler, encoding the aspects as an array of
+// aspect names.
+func (l aspectList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.names())
+}
+
+// flagNameCache returns the cache of the names of the allocation flags,
+// replacing it if the allocation flag constants changed.
+func (verb *memoryVerb) flagNameCache(allocationFlags *service.ConstantSet) *breakdown.FlagNameCache {
+	if verb.flagNames == nil || verb.flagNames.Constants() != allocationFlags {
+		verb.flagNames = breakdown.NewFlagNameCache(allocationFlags)
+	}
+	return verb.flagNames
+}
+
+// memoryTypeName returns the memory type index of the allocation, followed by
+// the names of the property flags of the type if they are known.
+func memoryTypeName(alloc *api.MemoryAllocation, flagNames *breakdown.FlagNameCache) string {
+	if alloc.Flags == 0 || flagNames.Constants() == nil {
+		return fmt.Sprint(alloc.MemoryType)
+	}
+	names := flagNames.Names(alloc.Flags)
+	return fmt.Sprintf("%v (%v)", alloc.MemoryType, strings.Join(names, " | "))
+}
+
+// isHostCoherent returns whether the memory of the allocation is host
+// coherent, so mapped ranges don't need to be explicitly flushed and
+// invalidated. known is false if the allocation flags don't tell.
+func isHostCoherent(alloc *api.MemoryAllocation, allocationFlags *service.ConstantSet) (coherent, known bool) {
+	if allocationFlags == nil || !allocationFlags.IsBitfield {
+		return false, false
+	}
+	for _, f := range allocationFlags.Constants {
+		if strings.Contains(f.Name, "HOST_COHERENT") {
+			return alloc.Flags&uint32(f.Value) != 0, true
+		}
+	}
+	return false, false
+}
+
+// sharerName returns the name used to print an alias sharer. The handle is
+// included if it isn't already the name of the binding.
+func (verb *memoryVerb) sharerName(handle uint64, names map[uint64]string) string {
+	name, ok := names[handle]
+	if !ok || name == "" || name == fmt.Sprint(handle) {
+		return verb.handle(handle)
+	}
+	return fmt.Sprintf("%v (%v)", name, verb.handle(handle))
+}
+
+// The JSON representation of the memory breakdown printed with -json.
+type (
+	memoryJSON struct {
+		Command     []uint64         `json:"command"`
+		Allocations []allocationJSON `json:"allocations"`
+	}
+	// allocationLineJSON is a line of -json-stream, an allocation along with
+	// the command of its snapshot.
+	allocationLineJSON struct {
+		Command []uint64 `json:"command"`
+		allocationJSON
+	}
+	allocationJSON struct {
+		ID         string          `json:"id,omitempty"`
+		Name       string          `json:"name"`
+		Handle     uint64          `json:"handle"`
+		Device     uint64          `json:"device"`
+		MemoryType uint32          `json:"memoryType"`
+		Size       uint64          `json:"size"`
+		Flags      uint32          `json:"flags"`
+		FlagNames  []string        `json:"flagNames"`
+		Mapping    *mappingJSON    `json:"mapping,omitempty"`
+		Bindings   []bindingJSON   `json:"bindings"`
+		Aliases    []aliasJSON     `json:"aliases"`
+		Overlaps   []aliasJSON     `json:"overlaps,omitempty"`
+		AliasPairs []aliasPairJSON `json:"aliasPairs,omitempty"`
+		Aliased    uint64          `json:"aliasedBytes"`
+
+		// OpaqueBlockOverlaps are the ranges of an image bound both by
+		// sparse opaque and sparse image blocks.
+		OpaqueBlockOverlaps []aliasPairJSON `json:"opaqueBlockOverlaps,omitempty"`
+	}
+	mappingJSON struct {
+		Offset        uint64 `json:"offset"`
+		Size          uint64 `json:"size"`
+		MappedAddress uint64 `json:"mappedAddress"`
+	}
+	bindingJSON struct {
+		Type   string `json:"type"`
+		Name   string `json:"name"`
+		Handle uint64 `json:"handle"`
+		Offset uint64 `json:"offset"`
+		Size   uint64 `json:"size"`
+
+		// Sparse binding specific information.
+		BlockOffset          *[2]int32  `json:"blockOffset,omitempty"`
+		BlockExtent          *[2]uint32 `json:"blockExtent,omitempty"`
+		MipLevel             *uint32    `json:"mipLevel,omitempty"`
+		ArrayLayer           *uint32    `json:"arrayLayer,omitempty"`
+		Aspects              aspectList `json:"aspects,omitempty"`
+		MipTailOffset        *uint64    `json:"mipTailOffset,omitempty"`
+		ResourceMemoryOffset *uint64    `json:"resourceMemoryOffset,omitempty"`
+	}
+	aliasJSON struct {
+		Offset      uint64   `json:"offset"`
+		Size        uint64   `json:"size"`
+		Kind        string   `json:"kind"`
+		Severity    string   `json:"severity,omitempty"`
+		Sharers     []uint64 `json:"sharers"`
+		SharerNames []string `json:"sharerNames"`
+	}
+	aliasPairJSON struct {
+		First  uint64 `json:"first"`
+		Second uint64 `json:"second"`
+		Start  uint64 `json:"start"`
+		End    uint64 `json:"end"`
+	}
+)
+
+func newBindingJSON(binding *api.MemoryBinding) bindingJSON {
+	out := bindingJSON{
+		Type:   breakdown.BindingTypeName(binding),
+		Name:   binding.Name,
+		Handle: binding.Handle,
+		Offset: binding.Offset,
+		Size:   binding.Size,
+	}
+	switch val := binding.Type.(type) {
+	case *api.MemoryBinding_SparseImageBlock:
+		info := val.SparseImageBlock
+		out.BlockOffset = &[2]int32{info.XOffset, info.YOffset}
+		out.BlockExtent = &[2]uint32{info.Width, info.Height}
+		out.MipLevel = &info.MipLevel
+		out.ArrayLayer = &info.ArrayLayer
+	case *api.MemoryBinding_SparseImageMetadata:
+		info := val.SparseImageMetadata
+		out.ArrayLayer = &info.ArrayLayer
+		out.MipTailOffset = &info.Offset
+	case *api.MemoryBinding_SparseImageMipTail:
+		info := val.SparseImageMipTail
+		out.ArrayLayer = &info.ArrayLayer
+		out.MipTailOffset = &info.Offset
+	case *api.MemoryBinding_SparseOpaqueImageBlock:
+		out.ResourceMemoryOffset = &val.SparseOpaqueImageBlock.Offset
+	case *api.MemoryBinding_SparseBufferBlock:
+		out.ResourceMemoryOffset = &val.SparseBufferBlock.Offset
+	}
+	if aspects := bindingAspects(binding); len(aspects) > 0 {
+		out.Aspects = aspects
+	}
+	return out
+}
+
+// newAliasJSON returns the JSON representation of the shared region a.
+func newAliasJSON(a breakdown.Alias, kind string, names map[uint64]string) aliasJSON {
+	sharerNames := make([]string, len(a.Sharers))
+	for i, s := range a.Sharers {
+		sharerNames[i] = names[s]
+	}
+	return aliasJSON{
+		Offset:      a.Offset,
+		Size:        a.Size,
+		Kind:        kind,
+		Sharers:     a.Sharers,
+		SharerNames: sharerNames,
+	}
+}
+
+// newAllocationJSON returns the JSON representation of the allocation, with
+// its bindings and aliased regions that pass the filter.
+func (verb *memoryVerb) newAllocationJSON(alloc *api.MemoryAllocation, flagNames *breakdown.FlagNameCache, filter allocationFilter) allocationJSON {
+	a := allocationJSON{
+		Name:       alloc.Name,
+		Handle:     alloc.Handle,
+		Device:     alloc.Device,
+		MemoryType: alloc.MemoryType,
+		Size:       alloc.Size,
+		Flags:      alloc.Flags,
+		FlagNames:  flagNames.Names(alloc.Flags),
+		Bindings:   []bindingJSON{},
+		Aliases:    []aliasJSON{},
+	}
+	if verb.Alloc.ID {
+		a.ID = allocationID(alloc)
+	}
+	if alloc.Mapping.Size != 0 {
+		a.Mapping = &mappingJSON{
+			Offset:        alloc.Mapping.Offset,
+			Size:          alloc.Mapping.Size,
+			MappedAddress: alloc.Mapping.MappedAddress,
+		}
+	}
+
+	bindings := breakdown.Bindings(alloc.Bindings)
+	sort.Slice(bindings, bindings.Less)
+	for _, binding := range verb.sortBindings(filter.bindings(bindings)) {
+		a.Bindings = append(a.Bindings, newBindingJSON(binding))
+	}
+	aliases, overlaps := breakdown.AllocationOverlaps(alloc)
+	aliases, overlaps = filter.aliases(aliases, bindings), filter.aliases(overlaps, bindings)
+	sortBySeverity(bindings, aliases)
+	names := bindings.Names()
+	for _, alias := range aliases {
+		kind, severity := alias.Kind(), bindings.AliasSeverity(alias)
+		alias.Sharers = verb.sortSharers(alias.Sharers, names)
+		j := newAliasJSON(alias, kind, names)
+		j.Severity = severity.String()
+		a.Aliases = append(a.Aliases, j)
+	}
+	for _, overlap := range overlaps {
+		overlap.Sharers = verb.sortSharers(overlap.Sharers, names)
+		a.Overlaps = append(a.Overlaps, newAliasJSON(overlap, "non-conflicting", names))
+	}
+	if verb.Alias.Pairs {
+		for _, p := range bindings.ComputeAliasPairs() {
+			a.AliasPairs = append(a.AliasPairs, aliasPairJSON{p.First.Handle, p.Second.Handle, p.Start, p.End})
+		}
+	}
+	for _, p := range filter.aliasPairs(bindings.OpaqueBlockOverlaps()) {
+		a.OpaqueBlockOverlaps = append(a.OpaqueBlockOverlaps, aliasPairJSON{p.First.Handle, p.Second.Handle, p.Start, p.End})
+	}
+	a.Aliased = aliasedBytes(aliases)
+	return a
+}
+
+// printMemoryJSON prints the memory breakdown after cmd, with the allocation
+// flag names and the aliased regions resolved, as JSON to stdout.
+func (verb *memoryVerb) printMemoryJSON(ctx context.Context, cmd *path.Command, mem *api.MemoryBreakdown, allocationFlags *service.ConstantSet, filter allocationFilter) error {
+	out := memoryJSON{
+		Command:     cmd.Indices,
+		Allocations: make([]allocationJSON, 0, len(mem.Allocations)),
+	}
+	flagNames := verb.flagNameCache(allocationFlags)
+	for _, alloc := range mem.Allocations {
+		out.Allocations = append(out.Allocations, verb.newAllocationJSON(alloc, flagNames, filter))
+	}
+
+	jsonBytes, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return log.Err(ctx, err, "Couldn't marshal memory breakdown to JSON")
+	}
+	fmt.Fprintln(verb.out, string(jsonBytes))
+	return nil
+}
+
+// printMemoryJSONStream prints the allocations of the snapshots as
+// newline-delimited JSON, one allocation per line, each encoded and written as
+// it is processed, so that the whole document is never built in memory.
+func (verb *memoryVerb) printMemoryJSONStream(ctx context.Context, snapshots []memorySnapshot, allocationFlags *service.ConstantSet, filter allocationFilter) error {
+	w := bufio.NewWriter(verb.out)
+	enc := json.NewEncoder(w)
+	flagNames := verb.flagNameCache(allocationFlags)
+	for _, snapshot := range snapshots {
+		for _, alloc := range snapshot.mem.Allocations {
+			line := allocationLineJSON{snapshot.cmd.Indices, verb.newAllocationJSON(alloc, flagNames, filter)}
+			if err := enc.Encode(line); err != nil {
+				return log.Errf(ctx, err, "Couldn't marshal allocation %v to JSON", alloc.Name)
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return log.Err(ctx, err, "Couldn't write the memory breakdown as JSON")
+	}
+	return nil
 }
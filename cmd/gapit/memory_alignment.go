@@ -0,0 +1,84 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// misalignedBinding is a binding whose offset or size is not a multiple of the
+// -page-size, along with its allocation and actual alignment.
+type misalignedBinding struct {
+	alloc     *api.MemoryAllocation
+	binding   *api.MemoryBinding
+	alignment uint64
+}
+
+// alignment returns the largest power of two that both the offset and size
+// are a multiple of, or 0 if they are both 0, and so aligned to anything.
+func alignment(offset, size uint64) uint64 {
+	n := offset | size
+	return n & -n
+}
+
+// misalignedBindings returns the bindings of the allocations kept by the
+// filter whose offset or size isn't a multiple of pageSize, in order of
+// allocation, then of offset.
+func misalignedBindings(allocs []*api.MemoryAllocation, pageSize uint64, filter allocationFilter) []misalignedBinding {
+	out := []misalignedBinding{}
+	for _, alloc := range allocs {
+		bindings := breakdown.Bindings(alloc.Bindings)
+		sort.Slice(bindings, bindings.Less)
+		for _, b := range filter.bindings(bindings) {
+			if b.Offset%pageSize != 0 || b.Size%pageSize != 0 {
+				out = append(out, misalignedBinding{alloc, b, alignment(b.Offset, b.Size)})
+			}
+		}
+	}
+	return out
+}
+
+// printMisalignedBindings prints the bindings of each snapshot that are not
+// aligned to the -page-size, with their actual alignment.
+func (verb *memoryVerb) printMisalignedBindings(snapshots []memorySnapshot, filter allocationFilter) error {
+	pageSize := uint64(verb.Page.Size)
+	for i, snapshot := range snapshots {
+		if len(snapshots) > 1 {
+			if i > 0 {
+				fmt.Fprintln(verb.out)
+			}
+			fmt.Fprintf(verb.out, "%v:\n", snapshot.header())
+		}
+		misaligned := misalignedBindings(snapshot.mem.Allocations, pageSize, filter)
+		w := verb.tabWriter(1)
+		fmt.Fprintf(w, "%v bindings not aligned to %v\n", len(misaligned), verb.bytes(pageSize))
+		if len(misaligned) > 0 {
+			fmt.Fprintln(w, "Allocation\tBinding\tType\tOffset\tSize\tRequired\tActual")
+		}
+		for _, m := range misaligned {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", m.alloc.Name, m.binding.Name,
+				breakdown.BindingTypeName(m.binding), m.binding.Offset, m.binding.Size,
+				pageSize, m.alignment)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/core/data/id"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// knownAPIs are the names of the APIs accepted by -api. The ID of an API is
+// the hash of its name.
+var knownAPIs = []string{"vulkan", "gles"}
+
+// checkAPI returns an error if -api isn't one of the known APIs.
+func (verb *memoryVerb) checkAPI() error {
+	if verb.Api == "" {
+		return nil
+	}
+	for _, name := range knownAPIs {
+		if verb.Api == name {
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown -api %q, expected one of %v", verb.Api, knownAPIs)
+}
+
+// apiPath returns the path of the API with the given name.
+func apiPath(name string) *path.API {
+	return &path.API{ID: path.NewID(id.OfString(name))}
+}
+
+// apiName returns the name of the API, or its ID if it isn't a known API.
+func apiName(p *path.API) string {
+	if !p.GetID().IsValid() {
+		return "unknown"
+	}
+	for _, name := range knownAPIs {
+		if p.ID.ID() == id.OfString(name) {
+			return name
+		}
+	}
+	return p.ID.ID().String()
+}
+
+// printAPI prints the API of the memory breakdown, and the API the flag names
+// are resolved with, if overridden with -api.
+func (verb *memoryVerb) printAPI(mem *api.MemoryBreakdown) {
+	detected := apiName(mem.API)
+	if verb.Api != "" && verb.Api != detected {
+		fmt.Fprintf(verb.out, "API: %v (flag names resolved with %v)\n", detected, verb.Api)
+	} else {
+		fmt.Fprintf(verb.out, "API: %v\n", detected)
+	}
+}
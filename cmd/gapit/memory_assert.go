@@ -0,0 +1,256 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/text"
+	"github.com/google/gapid/gapis/api"
+)
+
+// memoryAssertExit is the exit code of the memory verb when a rule of the
+// -assert file fails.
+const memoryAssertExit app.ExitCode = 5
+
+// The rules of an -assert file, one per line. Empty lines and lines starting
+// with # are ignored.
+//
+//	total OP SIZE                   the total size of the allocations
+//	count OP N                      the number of allocations
+//	alloc "NAME".size OP SIZE       the size of each allocation named NAME
+//	alloc "NAME".bindings OP N      the bindings of each allocation named NAME
+//	no [hard] aliases               no (hard, non-sparse) aliased regions
+//
+// OP is one of <, <=, >, >=, == or !=, and SIZE accepts unit suffixes, e.g.
+// 1GiB or 64M.
+var (
+	assertTotalRule = regexp.MustCompile(`^(total|count)\s*(<=|>=|==|!=|<|>)\s*(\S+)$`)
+	assertAllocRule = regexp.MustCompile(`^alloc\s+("(?:[^"\\]|\\.)*")\.(size|bindings)\s*(<=|>=|==|!=|<|>)\s*(\S+)$`)
+	assertAliasRule = regexp.MustCompile(`^no\s+(hard\s+)?aliases$`)
+)
+
+// assertRule is a single rule of an -assert file.
+type assertRule struct {
+	text string
+	// subject is total, count, size, bindings or aliases.
+	subject string
+	// name is the name of the allocations of a size or bindings rule.
+	name  string
+	op    string
+	value uint64
+	// hard is whether an aliases rule only applies to hard aliased regions.
+	hard bool
+}
+
+// assertResult is the result of a rule for the memory at a command.
+type assertResult struct {
+	cmd    []uint64
+	rule   assertRule
+	passed bool
+	// detail is the value the rule was checked against.
+	detail string
+}
+
+// parseAssertRule parses a single rule of an -assert file.
+func parseAssertRule(s string) (assertRule, error) {
+	rule := assertRule{text: s}
+	if m := assertTotalRule.FindStringSubmatch(s); m != nil {
+		rule.subject, rule.op = m[1], m[2]
+		value, err := parseAssertValue(rule.subject, m[3])
+		rule.value = value
+		return rule, err
+	}
+	if m := assertAllocRule.FindStringSubmatch(s); m != nil {
+		name, err := strconv.Unquote(m[1])
+		if err != nil {
+			return rule, fmt.Errorf("Invalid allocation name %v", m[1])
+		}
+		rule.name, rule.subject, rule.op = name, m[2], m[3]
+		value, err := parseAssertValue(rule.subject, m[4])
+		rule.value = value
+		return rule, err
+	}
+	if m := assertAliasRule.FindStringSubmatch(s); m != nil {
+		rule.subject, rule.hard = "aliases", m[1] != ""
+		return rule, nil
+	}
+	return rule, fmt.Errorf("Invalid rule %q", s)
+}
+
+// parseAssertValue parses the value a rule compares with, a size for the total
+// and size rules, otherwise a count.
+func parseAssertValue(subject, s string) (uint64, error) {
+	if subject == "total" || subject == "size" {
+		return text.ParseBytes(s)
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid count %q", s)
+	}
+	return n, nil
+}
+
+// parseAssertRules parses the rules of an -assert file.
+func parseAssertRules(r io.Reader) ([]assertRule, error) {
+	rules := []assertRule{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		s := strings.TrimSpace(scanner.Text())
+		if s == "" || strings.HasPrefix(s, "#") {
+			continue
+		}
+		rule, err := parseAssertRule(s)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// loadAssertRules loads the rules of the -assert file.
+func (verb *memoryVerb) loadAssertRules() error {
+	f, err := os.Open(verb.Assert)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	verb.assertRules, err = parseAssertRules(f)
+	if err == nil && len(verb.assertRules) == 0 {
+		return fmt.Errorf("%v has no rules", verb.Assert)
+	}
+	return err
+}
+
+// compare returns whether a OP b holds.
+func (rule assertRule) compare(a uint64) bool {
+	b := rule.value
+	switch rule.op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "==":
+		return a == b
+	default:
+		return a != b
+	}
+}
+
+// evalAssertRule returns whether the allocations pass the rule, along with the
+// value it was checked against. A size or bindings rule fails if there is no
+// allocation with its name, or if any of them fails it.
+func (verb *memoryVerb) evalAssertRule(rule assertRule, allocs []*api.MemoryAllocation) (bool, string) {
+	switch rule.subject {
+	case "total":
+		total := totalSize(allocs)
+		return rule.compare(total), verb.bytes(total)
+	case "count":
+		return rule.compare(uint64(len(allocs))), fmt.Sprint(len(allocs))
+	case "aliases":
+		count := 0
+		for _, alloc := range allocs {
+			for _, a := range alloc.Aliases {
+				if !rule.hard || !a.Sparse {
+					count++
+				}
+			}
+		}
+		if rule.hard {
+			return count == 0, fmt.Sprintf("%v hard aliased regions", count)
+		}
+		return count == 0, fmt.Sprintf("%v aliased regions", count)
+	}
+
+	found := false
+	for _, alloc := range allocs {
+		if alloc.Name != rule.name {
+			continue
+		}
+		found = true
+		if rule.subject == "size" && !rule.compare(alloc.Size) {
+			return false, verb.bytes(alloc.Size)
+		}
+		if rule.subject == "bindings" && !rule.compare(uint64(len(alloc.Bindings))) {
+			return false, fmt.Sprintf("%v bindings", len(alloc.Bindings))
+		}
+	}
+	if !found {
+		return false, fmt.Sprintf("no allocation named %q", rule.name)
+	}
+	return true, ""
+}
+
+// checkAssertions evaluates the rules of the -assert file against the
+// allocations kept by the filter at each snapshot. Like checkLimits, it must
+// be called before -top drops any allocation.
+func (verb *memoryVerb) checkAssertions(snapshots []memorySnapshot) []assertResult {
+	results := []assertResult{}
+	for _, snapshot := range snapshots {
+		for _, rule := range verb.assertRules {
+			passed, detail := verb.evalAssertRule(rule, snapshot.mem.Allocations)
+			results = append(results, assertResult{snapshot.cmd.Indices, rule, passed, detail})
+		}
+	}
+	return results
+}
+
+// printAssertions prints whether each rule passed or failed.
+func (verb *memoryVerb) printAssertions(results []assertResult) {
+	if len(results) == 0 {
+		return
+	}
+	fmt.Fprintln(verb.out)
+	w := verb.tabWriter(2)
+	for _, r := range results {
+		status := "PASS"
+		if !r.passed {
+			status = "FAIL"
+		}
+		if r.detail != "" {
+			fmt.Fprintf(w, "%v\t%v\t%v\t(%v)\n", status, r.cmd, r.rule.text, r.detail)
+		} else {
+			fmt.Fprintf(w, "%v\t%v\t%v\t\n", status, r.cmd, r.rule.text)
+		}
+	}
+	w.Flush()
+}
+
+// assertionFailures returns the failure of the rules that failed, if any.
+func assertionFailures(results []assertResult) []limitFailure {
+	failed := []string{}
+	for _, r := range results {
+		if !r.passed {
+			failed = append(failed, fmt.Sprintf("\t%v at command %v", r.rule.text, r.cmd))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return []limitFailure{{memoryAssertExit,
+		fmt.Sprintf("%v of the -assert rules failed:\n%v", len(failed), strings.Join(failed, "\n"))}}
+}
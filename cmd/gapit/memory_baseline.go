@@ -0,0 +1,105 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// memoryBaselineExit is the exit code of the memory verb when the memory
+// regressed over the -baseline-load file.
+const memoryBaselineExit app.ExitCode = 6
+
+// overTolerance returns whether the new size grew over the old one by more
+// than tolerance percent.
+func overTolerance(old, new uint64, tolerance float64) bool {
+	return float64(new) > float64(old)*(1+tolerance/100)
+}
+
+// baselineRegressions returns the regressions of a diff from the baseline:
+// the added allocations, and the changed allocations that grew by more than
+// tolerance percent.
+func baselineRegressions(diff memoryDiff, tolerance float64) memoryDiff {
+	out := memoryDiff{added: diff.added}
+	for _, d := range diff.changed {
+		if overTolerance(d.old.Size, d.new.Size, tolerance) {
+			out.changed = append(out.changed, d)
+		}
+	}
+	return out
+}
+
+// saveBaseline writes the unfiltered memory breakdown of the snapshot to the
+// -baseline-save file, as JSON, to be compared against with -baseline-load.
+func (verb *memoryVerb) saveBaseline(ctx context.Context, snapshot memorySnapshot) error {
+	data, err := breakdown.MarshalJSON(snapshot.mem)
+	if err != nil {
+		return log.Err(ctx, err, "Couldn't encode the memory breakdown")
+	}
+	if err := ioutil.WriteFile(verb.Baseline.Save, data, 0644); err != nil {
+		return log.Errf(ctx, err, "Failed to write %v", verb.Baseline.Save)
+	}
+	log.I(ctx, "Saved the memory breakdown at command %v as the baseline %v", snapshot.cmd.Indices, verb.Baseline.Save)
+	return nil
+}
+
+// loadBaseline reads the memory breakdown of the -baseline-load file.
+func (verb *memoryVerb) loadBaseline(ctx context.Context) (*api.MemoryBreakdown, error) {
+	data, err := ioutil.ReadFile(verb.Baseline.Load)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Failed to read the baseline %v", verb.Baseline.Load)
+	}
+	mem, err := breakdown.UnmarshalJSON(data)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Failed to decode the baseline %v", verb.Baseline.Load)
+	}
+	return mem, nil
+}
+
+// compareBaseline prints the regressions of the allocations kept by the
+// filter over the -baseline-load file, matching the allocations by name and
+// memory type like -compare-files. It fails if there are any, or if the total
+// size grew by more than -baseline-tolerance percent.
+func (verb *memoryVerb) compareBaseline(ctx context.Context, snapshot memorySnapshot, filter allocationFilter) error {
+	base, err := verb.loadBaseline(ctx)
+	if err != nil {
+		return err
+	}
+	base.Allocations = filter.apply(base.Allocations)
+
+	regressions := baselineRegressions(matchAllocations(base, snapshot.mem), verb.Baseline.Tolerance)
+	oldTotal, newTotal := totalSize(base.Allocations), totalSize(snapshot.mem.Allocations)
+	totalRegressed := overTolerance(oldTotal, newTotal, verb.Baseline.Tolerance)
+	if len(regressions.added) == 0 && len(regressions.changed) == 0 && !totalRegressed {
+		fmt.Fprintf(verb.out, "No regressions over the baseline %v, total %v -> %v (%v)\n", verb.Baseline.Load,
+			verb.bytes(oldTotal), verb.bytes(newTotal), verb.sizeDelta(oldTotal, newTotal))
+		return nil
+	}
+
+	header := fmt.Sprintf("Memory regressions over the baseline %v at command %v, with a tolerance of %v%%",
+		verb.Baseline.Load, snapshot.cmd.Indices, verb.Baseline.Tolerance)
+	verb.printDiff(header, regressions, base, snapshot.mem)
+	return reportLimits([]limitFailure{{memoryBaselineExit, fmt.Sprintf(
+		"%v regressed over the baseline %v: %v allocations added, %v grew, total %v",
+		snapshot.header(), verb.Baseline.Load, len(regressions.added), len(regressions.changed),
+		verb.sizeDelta(oldTotal, newTotal))}})
+}
@@ -0,0 +1,99 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// The ANSI escape sequences used to highlight the text output.
+const (
+	colorRed    = "\x1b[31m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// memoryTypeColors is the palette of the memory types in the -treemap and -dot
+// outputs. The color of a memory type only depends on its index, so that it is
+// the same across runs, and before/after outputs can be compared.
+var memoryTypeColors = []string{
+	"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462",
+	"#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f",
+}
+
+// memoryTypeColor returns the color of the memory type in the -treemap and
+// -dot outputs.
+func memoryTypeColor(memoryType uint32) string {
+	return memoryTypeColors[int(memoryType)%len(memoryTypeColors)]
+}
+
+// memoryTypeLegend returns the names of the memory types of the allocations,
+// with their property flags, and their colors, in increasing memory type.
+func memoryTypeLegend(allocs []*api.MemoryAllocation, flagNames *breakdown.FlagNameCache) (names, colors []string) {
+	for _, g := range groupByMemoryType(allocs) {
+		names = append(names, "Memory type "+memoryTypeName(g.allocations[0], flagNames))
+		colors = append(colors, memoryTypeColor(g.memoryType))
+	}
+	return names, colors
+}
+
+var (
+	stdoutIsTerminalOnce sync.Once
+	stdoutIsTerminal     bool
+)
+
+// checkColor returns an error if -color is not one of auto, always or never.
+func (verb *memoryVerb) checkColor() error {
+	switch verb.Color {
+	case "auto", "always", "never":
+		return nil
+	}
+	return fmt.Errorf("Unknown -color mode %q, expected auto, always or never", verb.Color)
+}
+
+// useColor returns whether the text output should be highlighted. With
+// -color=auto, it is only highlighted when stdout is a terminal, and the report
+// isn't written to an -out file.
+func (verb *memoryVerb) useColor() bool {
+	switch verb.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if verb.Out != "" {
+		return false
+	}
+	stdoutIsTerminalOnce.Do(func() {
+		fi, err := os.Stdout.Stat()
+		stdoutIsTerminal = err == nil && fi.Mode()&os.ModeCharDevice != 0
+	})
+	return stdoutIsTerminal
+}
+
+// colorize returns s highlighted with the color if -color is enabled. As the
+// escape sequences have no width on a terminal, but are counted by the
+// tabwriter, only the text after the last tab of a line may be highlighted.
+func (verb *memoryVerb) colorize(color, s string) string {
+	if !verb.useColor() {
+		return s
+	}
+	return color + s + colorReset
+}
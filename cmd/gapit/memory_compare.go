@@ -0,0 +1,95 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// allocationKey identifies an allocation across captures, where the handles
+// differ, by its name and memory type.
+type allocationKey struct {
+	name       string
+	memoryType uint32
+}
+
+// matchAllocations computes the changes to the allocations between the old
+// and new breakdowns of different captures. As the handles don't match across
+// captures, allocations are matched by name, memory type and size first, then
+// the remaining ones by name and memory type only, in order, and are reported
+// as changed if their sizes differ. The bindings aren't compared, as their
+// resource handles don't match either.
+func matchAllocations(old, new *api.MemoryBreakdown) memoryDiff {
+	unmatched := map[allocationKey][]*api.MemoryAllocation{}
+	for _, alloc := range old.Allocations {
+		key := allocationKey{alloc.Name, alloc.MemoryType}
+		unmatched[key] = append(unmatched[key], alloc)
+	}
+	take := func(alloc *api.MemoryAllocation, sameSize bool) *api.MemoryAllocation {
+		key := allocationKey{alloc.Name, alloc.MemoryType}
+		for i, o := range unmatched[key] {
+			if !sameSize || o.Size == alloc.Size {
+				unmatched[key] = append(unmatched[key][:i:i], unmatched[key][i+1:]...)
+				return o
+			}
+		}
+		return nil
+	}
+
+	diff := memoryDiff{}
+	rest := []*api.MemoryAllocation{}
+	for _, alloc := range new.Allocations {
+		if take(alloc, true) == nil {
+			rest = append(rest, alloc)
+		}
+	}
+	for _, alloc := range rest {
+		if o := take(alloc, false); o != nil {
+			diff.changed = append(diff.changed, allocationDiff{old: o, new: alloc})
+		} else {
+			diff.added = append(diff.added, alloc)
+		}
+	}
+	for _, alloc := range old.Allocations {
+		for _, o := range unmatched[allocationKey{alloc.Name, alloc.MemoryType}] {
+			if o == alloc {
+				diff.removed = append(diff.removed, alloc)
+			}
+		}
+	}
+	return diff
+}
+
+// compareFiles prints the changes to the allocations kept by the filter from
+// the old to the new capture file, at the -at point of each capture.
+func (verb *memoryVerb) compareFiles(ctx context.Context, oldFile, newFile string, filter allocationFilter) error {
+	snapshots := make([]memorySnapshot, 2)
+	for i, captureFile := range []string{oldFile, newFile} {
+		s, _, err := verb.getSnapshots(ctx, captureFile)
+		if err != nil {
+			return err
+		}
+		snapshots[i] = s[0]
+		snapshots[i].mem.Allocations = filter.apply(snapshots[i].mem.Allocations)
+	}
+	from, to := snapshots[0], snapshots[1]
+	header := fmt.Sprintf("Memory changes from %v at command %v to %v at command %v",
+		oldFile, from.cmd.Indices, newFile, to.cmd.Indices)
+	verb.printDiff(header, matchAllocations(from.mem, to.mem), from.mem, to.mem)
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// isAliased returns whether the binding is part of any of the aliased regions.
+func isAliased(b *api.MemoryBinding, aliases []breakdown.Alias) bool {
+	start, end := b.Offset, b.Offset+b.Size
+	for _, a := range aliases {
+		if a.Offset >= end || a.Offset+a.Size <= start {
+			continue
+		}
+		for _, s := range a.Sharers {
+			if s == b.Handle {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commandIndex formats the indices of a command as a single CSV field.
+func commandIndex(indices []uint64) string {
+	strs := make([]string, len(indices))
+	for i, idx := range indices {
+		strs[i] = fmt.Sprint(idx)
+	}
+	return strings.Join(strs, ".")
+}
+
+// printMemoryCSV prints one row per binding of the snapshots as CSV to
+// stdout. Sizes are always printed as raw byte counts. Allocations without any
+// bindings are printed as a single row with empty binding columns.
+func (verb *memoryVerb) printMemoryCSV(ctx context.Context, snapshots []memorySnapshot, filter allocationFilter) error {
+	multi := len(snapshots) > 1
+	w := csv.NewWriter(verb.out)
+
+	header := []string{
+		"allocation", "device", "memory_type", "allocation_size",
+		"binding_type", "binding_name", "offset", "size", "aliased",
+	}
+	if multi {
+		header = append([]string{"command_index"}, header...)
+	}
+	w.Write(header)
+
+	for _, snapshot := range snapshots {
+		for _, alloc := range snapshot.mem.Allocations {
+			bindings := breakdown.Bindings(alloc.Bindings)
+			sort.Slice(bindings, bindings.Less)
+			aliases, _ := breakdown.AllocationOverlaps(alloc)
+			aliases = filter.aliases(aliases, bindings)
+
+			prefix := []string{
+				alloc.Name,
+				fmt.Sprint(alloc.Device),
+				fmt.Sprint(alloc.MemoryType),
+				fmt.Sprint(alloc.Size),
+			}
+			if multi {
+				prefix = append([]string{commandIndex(snapshot.cmd.Indices)}, prefix...)
+			}
+
+			shown := verb.sortBindings(filter.bindings(bindings))
+			if len(shown) == 0 {
+				w.Write(append(prefix, "", "", "", "", ""))
+				continue
+			}
+			for _, b := range shown {
+				w.Write(append(prefix[:len(prefix):len(prefix)],
+					breakdown.BindingTypeName(b),
+					b.Name,
+					fmt.Sprint(b.Offset),
+					fmt.Sprint(b.Size),
+					fmt.Sprint(isAliased(b, aliases)),
+				))
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return log.Err(ctx, err, "Couldn't write memory breakdown as CSV")
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/core/os/device"
+)
+
+// vendorNVIDIA is the PCI vendor ID of NVIDIA, whose drivers encode their
+// Vulkan driver version differently.
+const vendorNVIDIA = 0x10de
+
+// vulkanDriverVersion returns the driver version of the physical device,
+// decoded with the vendor's encoding.
+func vulkanDriverVersion(pd *device.VulkanPhysicalDevice) string {
+	v := pd.DriverVersion
+	if pd.VendorId == vendorNVIDIA {
+		return fmt.Sprintf("%v.%v", v>>22, (v>>14)&0xff)
+	}
+	return fmt.Sprintf("%v.%v.%v", v>>22, (v>>12)&0x3ff, v&0xfff)
+}
+
+// describeDevice returns the GPU and driver of the device the capture was
+// traced on, or an empty string if the capture doesn't record them. The
+// capture only records the physical devices, not which one each allocation's
+// device was created from, so the Vulkan physical device is only used if it
+// is the only one.
+func describeDevice(d *device.Instance) string {
+	config := d.GetConfiguration()
+	vulkan := config.GetDrivers().GetVulkan()
+	name, driver := config.GetHardware().GetGPU().GetName(), vulkan.GetVersion()
+	if pds := vulkan.GetPhysicalDevices(); len(pds) == 1 {
+		name = pds[0].DeviceName
+		if driver == "" {
+			driver = vulkanDriverVersion(pds[0])
+		}
+	}
+	switch {
+	case name == "":
+		return ""
+	case driver == "":
+		return name
+	default:
+		return fmt.Sprintf("%v, driver %v", name, driver)
+	}
+}
+
+// singleDevice returns the device of the allocations of the snapshots, if they
+// are all on the same one. The capture doesn't record which of its devices was
+// created on the GPU it was traced on, so only a single device is known to be.
+func singleDevice(snapshots []memorySnapshot) (uint64, bool) {
+	dev, found := uint64(0), false
+	for _, snapshot := range snapshots {
+		for _, alloc := range snapshot.mem.Allocations {
+			if found && alloc.Device != dev {
+				return 0, false
+			}
+			dev, found = alloc.Device, true
+		}
+	}
+	return dev, found
+}
+
+// deviceName returns the device of an allocation, followed by the GPU and
+// driver of the device the capture was traced on if it is that device.
+func (verb *memoryVerb) deviceName(dev uint64) string {
+	if verb.traceDevice == "" || !verb.traceDeviceKnown || dev != verb.traceDeviceID {
+		return fmt.Sprint(dev)
+	}
+	return fmt.Sprintf("%v (%v)", dev, verb.traceDevice)
+}
@@ -0,0 +1,254 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// memoryDiff holds the changes in memory allocations between two snapshots.
+type memoryDiff struct {
+	added   []*api.MemoryAllocation
+	removed []*api.MemoryAllocation
+	changed []allocationDiff
+}
+
+// allocationDiff holds the changes of a single allocation that is present in
+// both snapshots.
+type allocationDiff struct {
+	old, new *api.MemoryAllocation
+
+	addedBindings   []*api.MemoryBinding
+	removedBindings []*api.MemoryBinding
+	changedBindings []bindingDiff
+}
+
+type bindingDiff struct {
+	old, new *api.MemoryBinding
+}
+
+func (d allocationDiff) mappingChanged() bool {
+	return (d.old.GetMapping().GetSize() != 0) != (d.new.GetMapping().GetSize() != 0)
+}
+
+func (d allocationDiff) empty() bool {
+	return d.old.Size == d.new.Size && !d.mappingChanged() &&
+		len(d.addedBindings) == 0 && len(d.removedBindings) == 0 &&
+		len(d.changedBindings) == 0
+}
+
+// bindingKey identifies a binding by the resource and the region of the
+// resource that is bound, so that a binding can be matched across snapshots
+// even if it was moved within the allocation.
+func bindingKey(b *api.MemoryBinding) string {
+	key := fmt.Sprintf("%v:%v", b.Handle, breakdown.BindingTypeName(b))
+	switch val := b.Type.(type) {
+	case *api.MemoryBinding_SparseImageBlock:
+		info := val.SparseImageBlock
+		key += fmt.Sprintf(":%v:%v:%v:%v:%v", info.MipLevel, info.ArrayLayer,
+			info.XOffset, info.YOffset, info.Aspects)
+	case *api.MemoryBinding_SparseImageMetadata:
+		info := val.SparseImageMetadata
+		key += fmt.Sprintf(":%v:%v", info.ArrayLayer, info.Offset)
+	case *api.MemoryBinding_SparseImageMipTail:
+		info := val.SparseImageMipTail
+		key += fmt.Sprintf(":%v:%v:%v", info.ArrayLayer, info.Offset, info.Aspects)
+	case *api.MemoryBinding_SparseOpaqueImageBlock:
+		key += fmt.Sprintf(":%v", val.SparseOpaqueImageBlock.Offset)
+	case *api.MemoryBinding_SparseBufferBlock:
+		key += fmt.Sprintf(":%v", val.SparseBufferBlock.Offset)
+	}
+	return key
+}
+
+// diffMemory computes the changes to the allocations between old and new.
+// Allocations are matched by their identity, see allocationID, so that an
+// allocation reusing the handle of a freed one is not taken for it. The
+// allocations of both breakdowns are expected to be sorted by handle.
+func diffMemory(old, new *api.MemoryBreakdown) memoryDiff {
+	diff := memoryDiff{}
+	oldAllocs := map[string]*api.MemoryAllocation{}
+	for _, alloc := range old.Allocations {
+		oldAllocs[allocationID(alloc)] = alloc
+	}
+	newAllocs := map[string]*api.MemoryAllocation{}
+	for _, alloc := range new.Allocations {
+		newAllocs[allocationID(alloc)] = alloc
+	}
+
+	for _, alloc := range old.Allocations {
+		if _, ok := newAllocs[allocationID(alloc)]; !ok {
+			diff.removed = append(diff.removed, alloc)
+		}
+	}
+	for _, alloc := range new.Allocations {
+		o, ok := oldAllocs[allocationID(alloc)]
+		if !ok {
+			diff.added = append(diff.added, alloc)
+			continue
+		}
+		if d := diffAllocation(o, alloc); !d.empty() {
+			diff.changed = append(diff.changed, d)
+		}
+	}
+	return diff
+}
+
+func diffAllocation(old, new *api.MemoryAllocation) allocationDiff {
+	diff := allocationDiff{old: old, new: new}
+
+	oldBindings := breakdown.Bindings(old.Bindings)
+	sort.Slice(oldBindings, oldBindings.Less)
+	newBindings := breakdown.Bindings(new.Bindings)
+	sort.Slice(newBindings, newBindings.Less)
+
+	oldByKey := map[string]*api.MemoryBinding{}
+	for _, b := range oldBindings {
+		oldByKey[bindingKey(b)] = b
+	}
+	newByKey := map[string]*api.MemoryBinding{}
+	for _, b := range newBindings {
+		newByKey[bindingKey(b)] = b
+	}
+
+	for _, b := range oldBindings {
+		if _, ok := newByKey[bindingKey(b)]; !ok {
+			diff.removedBindings = append(diff.removedBindings, b)
+		}
+	}
+	for _, b := range newBindings {
+		o, ok := oldByKey[bindingKey(b)]
+		if !ok {
+			diff.addedBindings = append(diff.addedBindings, b)
+		} else if o.Offset != b.Offset || o.Size != b.Size {
+			diff.changedBindings = append(diff.changedBindings, bindingDiff{o, b})
+		}
+	}
+	return diff
+}
+
+// growth returns the diff restricted to the allocations and bindings that were
+// added, and the allocations and bindings that grew.
+func (diff memoryDiff) growth() memoryDiff {
+	out := memoryDiff{added: diff.added}
+	for _, d := range diff.changed {
+		grown := allocationDiff{old: d.old, new: d.new, addedBindings: d.addedBindings}
+		for _, b := range d.changedBindings {
+			if b.new.Size > b.old.Size {
+				grown.changedBindings = append(grown.changedBindings, b)
+			}
+		}
+		if d.new.Size > d.old.Size || len(grown.addedBindings) != 0 || len(grown.changedBindings) != 0 {
+			out.changed = append(out.changed, grown)
+		}
+	}
+	return out
+}
+
+// printMemoryDiff prints the allocations that were added, removed or changed
+// between the from and to snapshots. With -since, only the allocations and
+// bindings that were added or grew are printed.
+func (verb *memoryVerb) printMemoryDiff(from, to memorySnapshot) {
+	diff := diffMemory(from.mem, to.mem)
+	if len(verb.Since) != 0 {
+		diff = diff.growth()
+	}
+	header := fmt.Sprintf("Memory changes from command %v to %v", from.cmd.Indices, to.cmd.Indices)
+	verb.printDiff(header, diff, from.mem, to.mem)
+}
+
+// printDiff prints the header, then the changes of the diff between the old
+// and new breakdowns, and the change of their total size.
+func (verb *memoryVerb) printDiff(header string, diff memoryDiff, old, new *api.MemoryBreakdown) {
+	w := verb.tabWriter(0)
+	fmt.Fprintln(w, header)
+	fmt.Fprintf(w, "%v allocations added, %v removed, %v changed\n",
+		len(diff.added), len(diff.removed), len(diff.changed))
+
+	for _, alloc := range diff.added {
+		fmt.Fprintln(w, "Added:", alloc.Name)
+		fmt.Fprintf(w, "\tSize: \t%v\n", verb.bytes(alloc.Size))
+		fmt.Fprintf(w, "\t%v bindings\n", len(alloc.Bindings))
+	}
+	for _, alloc := range diff.removed {
+		fmt.Fprintln(w, "Removed:", alloc.Name)
+		fmt.Fprintf(w, "\tSize: \t%v\n", verb.bytes(alloc.Size))
+		fmt.Fprintf(w, "\t%v bindings\n", len(alloc.Bindings))
+	}
+	for _, d := range diff.changed {
+		fmt.Fprintln(w, "Changed:", d.new.Name)
+		if d.old.Size != d.new.Size {
+			fmt.Fprintf(w, "\tSize: \t%v -> %v (%v)\n", verb.bytes(d.old.Size), verb.bytes(d.new.Size), verb.sizeDelta(d.old.Size, d.new.Size))
+		}
+		if d.mappingChanged() {
+			if d.new.Mapping.Size != 0 {
+				fmt.Fprintf(w, "\tMapped into host memory at 0x%x\n",
+					d.new.Mapping.MappedAddress)
+			} else {
+				fmt.Fprintln(w, "\tUnmapped from host memory")
+			}
+		}
+		for _, b := range d.addedBindings {
+			fmt.Fprintf(w, "\tAdded %v: %v\n", breakdown.BindingTypeName(b), b.Name)
+			fmt.Fprintf(w, "\t\tOffset: \t%v\n", b.Offset)
+			fmt.Fprintf(w, "\t\tSize: \t%v\n", verb.bytes(b.Size))
+		}
+		for _, b := range d.removedBindings {
+			fmt.Fprintf(w, "\tRemoved %v: %v\n", breakdown.BindingTypeName(b), b.Name)
+			fmt.Fprintf(w, "\t\tOffset: \t%v\n", b.Offset)
+			fmt.Fprintf(w, "\t\tSize: \t%v\n", verb.bytes(b.Size))
+		}
+		for _, b := range d.changedBindings {
+			fmt.Fprintf(w, "\tChanged %v: %v\n", breakdown.BindingTypeName(b.new), b.new.Name)
+			if b.old.Offset != b.new.Offset {
+				fmt.Fprintf(w, "\t\tOffset: \t%v -> %v\n", b.old.Offset, b.new.Offset)
+			}
+			if b.old.Size != b.new.Size {
+				fmt.Fprintf(w, "\t\tSize: \t%v -> %v (%v)\n", verb.bytes(b.old.Size), verb.bytes(b.new.Size), verb.sizeDelta(b.old.Size, b.new.Size))
+			}
+		}
+	}
+	oldTotal, newTotal := totalSize(old.Allocations), totalSize(new.Allocations)
+	fmt.Fprintf(w, "Total: \t%v -> %v (%v)\n", verb.bytes(oldTotal), verb.bytes(newTotal), verb.sizeDelta(oldTotal, newTotal))
+	w.Flush()
+}
+
+// totalSize returns the sum of the sizes of the allocations.
+func totalSize(allocs []*api.MemoryAllocation) uint64 {
+	total := uint64(0)
+	for _, alloc := range allocs {
+		total += alloc.Size
+	}
+	return total
+}
+
+// sizeDelta formats the signed change from the old to the new size, both in
+// bytes and as a percentage of the old size, e.g. "+44 MiB, +17%". The
+// percentage is omitted when the old size is zero.
+func (verb *memoryVerb) sizeDelta(old, new uint64) string {
+	delta := "+" + verb.bytes(new-old)
+	if new < old {
+		delta = "-" + verb.bytes(old-new)
+	}
+	if old == 0 {
+		return delta
+	}
+	percent := (float64(new) - float64(old)) * 100 / float64(old)
+	return fmt.Sprintf("%v, %+.0f%%", delta, percent)
+}
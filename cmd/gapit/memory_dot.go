@@ -0,0 +1,99 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+	"github.com/google/gapid/gapis/service"
+)
+
+// printMemoryDOT prints the memory breakdown as a Graphviz graph to stdout.
+// Each allocation is drawn as a cluster containing its bindings, with an edge
+// from each binding to the resource it is bound to. Aliased regions are drawn
+// as red nodes, linked to the resources sharing them. The clusters are filled
+// with the color of their memory type, given by a legend cluster.
+func (verb *memoryVerb) printMemoryDOT(ctx context.Context, mem *api.MemoryBreakdown, allocationFlags *service.ConstantSet, filter allocationFilter) error {
+	w := bufio.NewWriter(verb.out)
+	fmt.Fprintln(w, "digraph memory {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box, style=filled, fillcolor=white];")
+
+	legend, colors := memoryTypeLegend(mem.Allocations, verb.flagNameCache(allocationFlags))
+	if len(legend) > 0 {
+		fmt.Fprintln(w, "  subgraph cluster_legend {")
+		fmt.Fprintln(w, "    label=\"Memory types\";")
+		for i, name := range legend {
+			fmt.Fprintf(w, "    legend_%v [label=%v, fillcolor=%v];\n", i, strconv.Quote(name), strconv.Quote(colors[i]))
+		}
+		fmt.Fprintln(w, "  }")
+	}
+
+	resources := map[uint64]string{}
+	edges := []string{}
+	for _, alloc := range mem.Allocations {
+		bindings := breakdown.Bindings(alloc.Bindings)
+		sort.Slice(bindings, bindings.Less)
+		names := bindings.Names()
+
+		fmt.Fprintf(w, "  subgraph cluster_%v {\n", alloc.Handle)
+		fmt.Fprintf(w, "    label=%v;\n", strconv.Quote(fmt.Sprintf("%v (%v)", alloc.Name, verb.bytes(alloc.Size))))
+		fmt.Fprintf(w, "    style=filled;\n    fillcolor=%v;\n", strconv.Quote(memoryTypeColor(alloc.MemoryType)))
+		for i, b := range filter.bindings(bindings) {
+			node := fmt.Sprintf("binding_%v_%v", alloc.Handle, i)
+			label := fmt.Sprintf("%v\n+%v %v", breakdown.BindingTypeName(b), b.Offset, verb.bytes(b.Size))
+			fmt.Fprintf(w, "    %v [label=%v];\n", node, strconv.Quote(label))
+			edges = append(edges, fmt.Sprintf("%v -> resource_%v", node, b.Handle))
+			resources[b.Handle] = verb.sharerName(b.Handle, names)
+		}
+		aliases, _ := breakdown.AllocationOverlaps(alloc)
+		aliases = filter.aliases(aliases, bindings)
+		for i, a := range aliases {
+			node := fmt.Sprintf("alias_%v_%v", alloc.Handle, i)
+			label := fmt.Sprintf("%v alias\n+%v %v", a.Kind(), a.Offset, verb.bytes(a.Size))
+			fmt.Fprintf(w, "    %v [label=%v, color=red, fontcolor=red];\n", node, strconv.Quote(label))
+			for _, s := range a.Sharers {
+				edges = append(edges, fmt.Sprintf("resource_%v -> %v [color=red, dir=none]", s, node))
+				resources[s] = verb.sharerName(s, names)
+			}
+		}
+		fmt.Fprintln(w, "  }")
+	}
+
+	handles := make([]uint64, 0, len(resources))
+	for h := range resources {
+		handles = append(handles, h)
+	}
+	sort.Slice(handles, func(i, j int) bool { return handles[i] < handles[j] })
+	for _, h := range handles {
+		fmt.Fprintf(w, "  resource_%v [shape=ellipse, label=%v];\n", h, strconv.Quote(resources[h]))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(w, "  %v;\n", e)
+	}
+	fmt.Fprintln(w, "}")
+
+	if err := w.Flush(); err != nil {
+		return log.Err(ctx, err, "Couldn't write memory breakdown as DOT")
+	}
+	return nil
+}
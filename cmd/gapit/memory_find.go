@@ -0,0 +1,88 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// resourceBinding is a binding of a resource, along with the allocation it
+// is bound to.
+type resourceBinding struct {
+	alloc   *api.MemoryAllocation
+	binding *api.MemoryBinding
+}
+
+// findResource returns all the bindings of the resource with the given handle,
+// in order of allocation handle, then of offset.
+func findResource(allocs []*api.MemoryAllocation, handle uint64) []resourceBinding {
+	out := []resourceBinding{}
+	for _, alloc := range allocs {
+		bindings := breakdown.Bindings(alloc.Bindings)
+		sort.Slice(bindings, bindings.Less)
+		for _, b := range bindings {
+			if b.Handle == handle {
+				out = append(out, resourceBinding{alloc, b})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].alloc.Handle < out[j].alloc.Handle })
+	return out
+}
+
+// findResourceHandle returns the handle given with -find-resource.
+func (verb *memoryVerb) findResourceHandle() (uint64, error) {
+	handle, err := strconv.ParseUint(verb.Find.Resource, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid -find-resource handle %q", verb.Find.Resource)
+	}
+	return handle, nil
+}
+
+// printResourceBindings prints where the resource given with -find-resource is
+// bound in each of the snapshots.
+func (verb *memoryVerb) printResourceBindings(snapshots []memorySnapshot) error {
+	handle, err := verb.findResourceHandle()
+	if err != nil {
+		return err
+	}
+	for i, snapshot := range snapshots {
+		if len(snapshots) > 1 {
+			if i > 0 {
+				fmt.Fprintln(verb.out)
+			}
+			fmt.Fprintf(verb.out, "%v:\n", snapshot.header())
+		}
+		found := findResource(snapshot.mem.Allocations, handle)
+		w := verb.tabWriter(1)
+		fmt.Fprintf(w, "Resource %v has %v bindings\n", verb.handle(handle), len(found))
+		if len(found) > 0 {
+			fmt.Fprintln(w, "Allocation\tOffset\tSize\tType")
+		}
+		for _, f := range found {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", f.alloc.Name, f.binding.Offset,
+				verb.bytes(f.binding.Size), breakdown.BindingTypeName(f.binding))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
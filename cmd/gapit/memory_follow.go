@@ -0,0 +1,129 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/google/gapid/core/app/status"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// The events of the timeline printed with -follow-resource, when the bindings
+// of the resource changed since the previous sample.
+const (
+	followBound   = "bound"
+	followRebound = "rebound"
+	followUnbound = "unbound"
+)
+
+// followSample is where the resource followed with -follow-resource is bound
+// after a command.
+type followSample struct {
+	command  []uint64
+	bindings []resourceBinding
+}
+
+// followEvent returns how the bindings of the resource changed between two
+// consecutive samples, or an empty string if they didn't.
+func followEvent(prev, next []resourceBinding) string {
+	switch {
+	case len(prev) == 0 && len(next) == 0:
+		return ""
+	case len(prev) == 0:
+		return followBound
+	case len(next) == 0:
+		return followUnbound
+	case len(prev) != len(next):
+		return followRebound
+	}
+	for i := range prev {
+		p, n := prev[i], next[i]
+		if p.alloc.Handle != n.alloc.Handle || p.binding.Offset != n.binding.Offset || p.binding.Size != n.binding.Size {
+			return followRebound
+		}
+	}
+	return ""
+}
+
+// followCommands returns the commands at which the resource is sampled: the
+// -at points if given, otherwise the same commands as -watch.
+func (verb *memoryVerb) followCommands(ctx context.Context, captureFile string) ([]*path.Command, error) {
+	if len(verb.At) != 0 {
+		cmds, _, err := verb.snapshotCommands(ctx, captureFile)
+		return cmds, err
+	}
+	capture, err := loadCapture(ctx, verb.gapis, captureFile, verb.CaptureFileFlags)
+	if err != nil {
+		return nil, err
+	}
+	return verb.sampleCommands(ctx, verb.gapis, capture)
+}
+
+// followResource prints a timeline of the bindings of the resource given with
+// -follow-resource, with a row per binding at each of the sampled commands,
+// and the event that changed them since the previous sample.
+func (verb *memoryVerb) followResource(ctx context.Context, captureFile string, filter allocationFilter) error {
+	handle, err := strconv.ParseUint(verb.Follow.Resource, 0, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid -follow-resource handle %q", verb.Follow.Resource)
+	}
+	cmds, err := verb.followCommands(ctx, captureFile)
+	if err != nil {
+		return err
+	}
+
+	samples := make([]followSample, len(cmds))
+	ctx = status.Start(ctx, "Following resource %v", verb.handle(handle))
+	defer status.Finish(ctx)
+	err = verb.fetchEach(ctx, verb.gapis, cmds, func(i int, mem *api.MemoryBreakdown) {
+		samples[i] = followSample{cmds[i].Indices, findResource(filter.apply(mem.Allocations), handle)}
+	})
+	if err != nil {
+		return err
+	}
+
+	bound := 0
+	w := verb.tabWriter(2)
+	fmt.Fprintln(w, "command_index\tevent\tallocation\toffset\tsize\ttype")
+	var prev []resourceBinding
+	for _, s := range samples {
+		event := followEvent(prev, s.bindings)
+		prev = s.bindings
+		if len(s.bindings) == 0 {
+			fmt.Fprintf(w, "%v\t%v\t-\t-\t-\t-\n", s.command, event)
+			continue
+		}
+		bound++
+		for i, b := range s.bindings {
+			if i > 0 {
+				fmt.Fprintf(w, "\t\t%v\t%v\t%v\t%v\n", b.alloc.Name, b.binding.Offset,
+					verb.bytes(b.binding.Size), breakdown.BindingTypeName(b.binding))
+				continue
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", s.command, event, b.alloc.Name, b.binding.Offset,
+				verb.bytes(b.binding.Size), breakdown.BindingTypeName(b.binding))
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(verb.out, "Resource %v is bound at %v of %v commands\n", verb.handle(handle), bound, len(samples))
+	return nil
+}
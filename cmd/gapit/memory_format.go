@@ -0,0 +1,85 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// formatFuncs are the helper functions available to the -format templates.
+var formatFuncs = template.FuncMap{
+	"humanBytes": humanBytes,
+	"hex":        func(n uint64) string { return fmt.Sprintf("0x%x", n) },
+}
+
+// formatAllocation is the data the -format template is executed with, once
+// per allocation.
+type formatAllocation struct {
+	// Command is the command/subcommand index of the memory breakdown.
+	Command []uint64
+	// Allocation is the allocation itself.
+	Allocation *api.MemoryAllocation
+	// Bindings are the bindings kept by the filter, in -sort-bindings order.
+	Bindings breakdown.Bindings
+	// Aliases and Overlaps are the shared regions of the allocation.
+	Aliases  []breakdown.Alias
+	Overlaps []breakdown.Alias
+}
+
+// formatTemplate parses the -format template, read from a file if the flag
+// starts with '@'.
+func (verb *memoryVerb) formatTemplate() (*template.Template, error) {
+	text := verb.Format
+	if strings.HasPrefix(text, "@") {
+		data, err := ioutil.ReadFile(text[1:])
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	return template.New("format").Funcs(formatFuncs).Parse(text)
+}
+
+// printMemoryFormat executes the -format template for each of the allocations
+// of the snapshot, instead of printing the built-in layout.
+func (verb *memoryVerb) printMemoryFormat(ctx context.Context, w io.Writer, tmpl *template.Template, snapshot memorySnapshot, filter allocationFilter) error {
+	for _, alloc := range snapshot.mem.Allocations {
+		bindings := breakdown.Bindings(alloc.Bindings)
+		sort.Slice(bindings, bindings.Less)
+		aliases, overlaps := breakdown.AllocationOverlaps(alloc)
+		aliases, overlaps = filter.aliases(aliases, bindings), filter.aliases(overlaps, bindings)
+		data := formatAllocation{
+			Command:    snapshot.cmd.Indices,
+			Allocation: alloc,
+			Bindings:   verb.sortBindings(filter.bindings(bindings)),
+			Aliases:    aliases,
+			Overlaps:   overlaps,
+		}
+		if err := tmpl.Execute(w, data); err != nil {
+			return log.Errf(ctx, err, "Failed to execute the -format template for %v", alloc.Name)
+		}
+	}
+	return nil
+}
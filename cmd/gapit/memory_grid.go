@@ -0,0 +1,152 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// sparseSubresource is a single mip level of an array layer of a sparse image.
+type sparseSubresource struct {
+	mipLevel   uint32
+	arrayLayer uint32
+}
+
+// sparseImage is a sparse image resource and its bound blocks, grouped by
+// subresource.
+type sparseImage struct {
+	handle uint64
+	name   string
+	blocks map[sparseSubresource][]*api.SparseImageBlock
+}
+
+// collectSparseImages returns the sparse images with blocks bound to the
+// allocations, sorted by handle.
+func collectSparseImages(allocs []*api.MemoryAllocation) []*sparseImage {
+	images := map[uint64]*sparseImage{}
+	for _, alloc := range allocs {
+		for _, binding := range alloc.Bindings {
+			block, ok := binding.Type.(*api.MemoryBinding_SparseImageBlock)
+			if !ok {
+				continue
+			}
+			img, ok := images[binding.Handle]
+			if !ok {
+				img = &sparseImage{
+					handle: binding.Handle,
+					name:   binding.Name,
+					blocks: map[sparseSubresource][]*api.SparseImageBlock{},
+				}
+				images[binding.Handle] = img
+			}
+			sub := sparseSubresource{block.SparseImageBlock.MipLevel, block.SparseImageBlock.ArrayLayer}
+			img.blocks[sub] = append(img.blocks[sub], block.SparseImageBlock)
+		}
+	}
+
+	out := make([]*sparseImage, 0, len(images))
+	for _, img := range images {
+		out = append(out, img)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].handle < out[j].handle })
+	return out
+}
+
+// subresources returns the subresources of the image with bound blocks,
+// sorted by array layer, then mip level.
+func (img *sparseImage) subresources() []sparseSubresource {
+	subs := make([]sparseSubresource, 0, len(img.blocks))
+	for sub := range img.blocks {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].arrayLayer != subs[j].arrayLayer {
+			return subs[i].arrayLayer < subs[j].arrayLayer
+		}
+		return subs[i].mipLevel < subs[j].mipLevel
+	})
+	return subs
+}
+
+// sparseGrid returns the rows of the block residency grid of a subresource,
+// where '#' is a bound block and '.' an unbound one. The block size is the
+// largest bound block extent, as the blocks at the right and bottom edges of
+// the image may be smaller. The image extent is not part of the memory
+// breakdown, so the grid stops at the last bound block in each dimension.
+func sparseGrid(blocks []*api.SparseImageBlock) []string {
+	blockWidth, blockHeight := uint32(1), uint32(1)
+	for _, b := range blocks {
+		if b.Width > blockWidth {
+			blockWidth = b.Width
+		}
+		if b.Height > blockHeight {
+			blockHeight = b.Height
+		}
+	}
+
+	columns, rows := 0, 0
+	bound := map[[2]int]bool{}
+	for _, b := range blocks {
+		if b.XOffset < 0 || b.YOffset < 0 {
+			continue
+		}
+		x, y := int(b.XOffset)/int(blockWidth), int(b.YOffset)/int(blockHeight)
+		bound[[2]int{x, y}] = true
+		if x >= columns {
+			columns = x + 1
+		}
+		if y >= rows {
+			rows = y + 1
+		}
+	}
+
+	grid := make([]string, rows)
+	for y := range grid {
+		row := make([]byte, columns)
+		for x := range row {
+			if bound[[2]int{x, y}] {
+				row[x] = '#'
+			} else {
+				row[x] = '.'
+			}
+		}
+		grid[y] = string(row)
+	}
+	return grid
+}
+
+// printSparseGrids prints the block residency grid of each subresource of the
+// sparse images bound to the allocations.
+func (verb *memoryVerb) printSparseGrids(w io.Writer, allocs []*api.MemoryAllocation) {
+	images := collectSparseImages(allocs)
+	fmt.Fprintf(w, "%v sparse images\n", len(images))
+	for _, img := range images {
+		fmt.Fprintf(w, "Name: %v\n", img.name)
+		fmt.Fprintf(w, "    Handle: %v\n", verb.handle(img.handle))
+		for _, sub := range img.subresources() {
+			blocks := img.blocks[sub]
+			grid := sparseGrid(blocks)
+			fmt.Fprintf(w, "    Mip Level %v, Array Layer %v: %v bound blocks\n",
+				sub.mipLevel, sub.arrayLayer, len(blocks))
+			for _, row := range grid {
+				fmt.Fprintf(w, "        %v\n", row)
+			}
+		}
+	}
+}
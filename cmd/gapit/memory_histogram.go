@@ -0,0 +1,71 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// bindingTypeCount is the number of bindings of a single type.
+type bindingTypeCount struct {
+	name  string
+	count int
+}
+
+// bindingHistogram summarizes the bindings of an allocation by type and size.
+type bindingHistogram struct {
+	types            []bindingTypeCount
+	min, median, max uint64
+}
+
+// newBindingHistogram returns the histogram of the bindings, with the types
+// sorted by name. The median of an even number of bindings is the lower one.
+func newBindingHistogram(bindings []*api.MemoryBinding) bindingHistogram {
+	h := bindingHistogram{}
+	if len(bindings) == 0 {
+		return h
+	}
+	counts := map[string]int{}
+	sizes := make([]uint64, len(bindings))
+	for i, b := range bindings {
+		counts[breakdown.BindingTypeName(b)]++
+		sizes[i] = b.Size
+	}
+	for name, count := range counts {
+		h.types = append(h.types, bindingTypeCount{name, count})
+	}
+	sort.Slice(h.types, func(i, j int) bool { return h.types[i].name < h.types[j].name })
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	h.min, h.median, h.max = sizes[0], sizes[(len(sizes)-1)/2], sizes[len(sizes)-1]
+	return h
+}
+
+// printBindingHistogram prints the number of bindings of each type, and the
+// distribution of their sizes, instead of the details of each binding.
+func (verb *memoryVerb) printBindingHistogram(w io.Writer, bindings []*api.MemoryBinding) {
+	h := newBindingHistogram(bindings)
+	for _, t := range h.types {
+		fmt.Fprintf(w, "\t\t%v: \t%v\n", t.name, t.count)
+	}
+	if len(bindings) > 0 {
+		fmt.Fprintf(w, "\t\tSize: \tmin %v, median %v, max %v\n",
+			verb.bytes(h.min), verb.bytes(h.median), verb.bytes(h.max))
+	}
+}
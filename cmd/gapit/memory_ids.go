@@ -0,0 +1,34 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// allocationID returns the synthetic identity of an allocation, stable across
+// the snapshots and the commands they are taken at. It is made of the handle
+// and the command that allocated the memory, as a handle can be reused by a
+// new allocation once freed, but not by two allocations live at once. The
+// allocations made before the start of the capture have an unknown allocating
+// command, and are only identified by their handle.
+func allocationID(alloc *api.MemoryAllocation) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%v\x00%v", alloc.Handle, alloc.AllocatedBy)
+	return fmt.Sprintf("%016x", h.Sum64())
+}
@@ -0,0 +1,127 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/google/gapid/core/app/flags"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// resolveCommandPoint returns the command/subcommand indices of the -at point.
+// first and last are the first and last of the numCommands commands. frame:N
+// is the last command of the N-th frame, counting from 1 as with the
+// screenshot verb. draw:NAME is the last command of the first group of the
+// command tree named NAME, such as a debug marker or a draw call group.
+func resolveCommandPoint(ctx context.Context, client service.Service, capture *path.Capture, numCommands uint64, p CommandPoint) (flags.U64Slice, error) {
+	switch p.Label {
+	case "":
+		return p.Indices, nil
+	case "first":
+		return flags.U64Slice{0}, nil
+	case "last":
+		if numCommands == 0 {
+			return nil, log.Err(ctx, nil, "The capture has no commands")
+		}
+		return flags.U64Slice{numCommands - 1}, nil
+	}
+	i := strings.Index(p.Label, ":")
+	kind, value := p.Label[:i], p.Label[i+1:]
+	switch kind {
+	case "frame":
+		frame, err := strconv.Atoi(value)
+		if err != nil || frame < 1 {
+			return nil, log.Errf(ctx, err, "Invalid frame number %q in %v", value, p.Label)
+		}
+		return frameCommand(ctx, client, capture, frame)
+	default:
+		return groupCommand(ctx, client, capture, value)
+	}
+}
+
+// frameCommand returns the indices of the last command of the frame, counting
+// from 1.
+func frameCommand(ctx context.Context, client service.Service, capture *path.Capture, frame int) (flags.U64Slice, error) {
+	ends, err := frameEnds(ctx, client, capture)
+	if err != nil {
+		return nil, err
+	}
+	if frame > len(ends) {
+		return nil, log.Errf(ctx, nil, "Invalid frame number %d (last frame is %d)", frame, len(ends))
+	}
+	return ends[frame-1].Indices, nil
+}
+
+// frameEnds returns the last command of each frame of the capture, in order.
+func frameEnds(ctx context.Context, client service.Service, capture *path.Capture) ([]*path.Command, error) {
+	events, err := getEvents(ctx, client, &path.Events{
+		Capture:     capture,
+		LastInFrame: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ends := []*path.Command{}
+	for _, e := range events {
+		if e.Kind == service.EventKind_LastInFrame {
+			ends = append(ends, e.Command)
+		}
+	}
+	return ends, nil
+}
+
+// groupCommand returns the indices of the last command of the first group of
+// the command tree named name.
+func groupCommand(ctx context.Context, client service.Service, capture *path.Capture, name string) (flags.U64Slice, error) {
+	treePath := capture.CommandTree(nil)
+	treePath.GroupByFrame = true
+	treePath.GroupByDrawCall = true
+	treePath.GroupByUserMarkers = true
+	boxedTree, err := client.Get(ctx, treePath.Path(), nil)
+	if err != nil {
+		return nil, log.Err(ctx, err, "Failed to load the command tree")
+	}
+
+	var group *service.CommandTreeNode
+	err = client.Find(ctx, &service.FindRequest{
+		From:            &service.FindRequest_CommandTreeNode{CommandTreeNode: boxedTree.(*service.CommandTree).Root},
+		Text:            name,
+		IsCaseSensitive: true,
+	}, func(r *service.FindResponse) error {
+		if group != nil {
+			return nil
+		}
+		boxedNode, err := client.Get(ctx, r.GetCommandTreeNode().Path(), nil)
+		if err != nil {
+			return err
+		}
+		if n := boxedNode.(*service.CommandTreeNode); n.Group == name {
+			group = n
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Failed to find the command group %q", name)
+	}
+	if group == nil {
+		return nil, log.Errf(ctx, nil, "No command group named %q", name)
+	}
+	return group.Commands.Last().Indices, nil
+}
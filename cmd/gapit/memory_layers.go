@@ -0,0 +1,178 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// The rows of the layer matrix of the sparse bindings that aren't of a single
+// mip level, after the mip levels.
+const (
+	mipTailRow  = math.MaxUint32 - 1
+	metadataRow = math.MaxUint32
+)
+
+// layerCell is a cell of the layer matrix of an image: a mip level, or the
+// mip tail or metadata row, of an array layer.
+type layerCell struct {
+	mipLevel   uint32
+	arrayLayer uint32
+}
+
+// imageLayers is the memory bound to each subresource of an image, per set of
+// aspects.
+type imageLayers struct {
+	name     string
+	bindings int
+	aspects  map[string]map[layerCell]uint64
+	// whole is the size of the bindings of the whole image, such as the image
+	// and the opaque sparse bindings, which aren't of a single subresource.
+	whole uint64
+}
+
+// collectImageLayers returns the memory bound to each subresource of the image
+// with the given handle, summing the sparse image blocks, mip tail and
+// metadata bindings of each mip level, array layer and aspects.
+func collectImageLayers(allocs []*api.MemoryAllocation, handle uint64) imageLayers {
+	layers := imageLayers{aspects: map[string]map[layerCell]uint64{}}
+	add := func(aspects []api.AspectType, cell layerCell, size uint64) {
+		key := fmt.Sprint(aspectList(aspects))
+		if layers.aspects[key] == nil {
+			layers.aspects[key] = map[layerCell]uint64{}
+		}
+		layers.aspects[key][cell] += size
+	}
+	for _, alloc := range allocs {
+		for _, b := range alloc.Bindings {
+			if b.Handle != handle {
+				continue
+			}
+			if layers.name == "" {
+				layers.name = b.Name
+			}
+			layers.bindings++
+			switch t := b.Type.(type) {
+			case *api.MemoryBinding_SparseImageBlock:
+				block := t.SparseImageBlock
+				add(block.Aspects, layerCell{block.MipLevel, block.ArrayLayer}, b.Size)
+			case *api.MemoryBinding_SparseImageMipTail:
+				tail := t.SparseImageMipTail
+				add(tail.Aspects, layerCell{mipTailRow, tail.ArrayLayer}, b.Size)
+			case *api.MemoryBinding_SparseImageMetadata:
+				metadata := t.SparseImageMetadata
+				add(metadata.Aspects, layerCell{metadataRow, metadata.ArrayLayer}, b.Size)
+			default:
+				layers.whole += b.Size
+			}
+		}
+	}
+	return layers
+}
+
+// layersHandle returns the handle given with -layers.
+func (verb *memoryVerb) layersHandle() (uint64, error) {
+	handle, err := strconv.ParseUint(verb.Layers, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid -layers handle %q", verb.Layers)
+	}
+	return handle, nil
+}
+
+// printImageLayers prints, for each set of aspects of the image given with
+// -layers, a matrix of the memory bound to each mip level (rows) and array
+// layer (columns) of the image, in each of the snapshots.
+func (verb *memoryVerb) printImageLayers(snapshots []memorySnapshot) error {
+	handle, err := verb.layersHandle()
+	if err != nil {
+		return err
+	}
+	for i, snapshot := range snapshots {
+		if len(snapshots) > 1 {
+			if i > 0 {
+				fmt.Fprintln(verb.out)
+			}
+			fmt.Fprintf(verb.out, "%v:\n", snapshot.header())
+		}
+		layers := collectImageLayers(snapshot.mem.Allocations, handle)
+		fmt.Fprintf(verb.out, "Image %v %v has %v bindings\n", verb.handle(handle), layers.name, layers.bindings)
+		if layers.whole > 0 {
+			fmt.Fprintf(verb.out, "Whole image bindings: %v\n", verb.bytes(layers.whole))
+		}
+
+		aspects := make([]string, 0, len(layers.aspects))
+		for a := range layers.aspects {
+			aspects = append(aspects, a)
+		}
+		sort.Strings(aspects)
+		for _, a := range aspects {
+			if err := verb.printLayerMatrix(a, layers.aspects[a]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printLayerMatrix prints the memory bound to each mip level and array layer
+// of an image for a set of aspects, with '-' for the unbound subresources.
+func (verb *memoryVerb) printLayerMatrix(aspects string, cells map[layerCell]uint64) error {
+	mipSet, layerSet := map[uint32]bool{}, map[uint32]bool{}
+	for c := range cells {
+		mipSet[c.mipLevel], layerSet[c.arrayLayer] = true, true
+	}
+	mips, arrayLayers := sortedKeys(mipSet), sortedKeys(layerSet)
+
+	fmt.Fprintf(verb.out, "Aspects %v:\n", aspects)
+	w := verb.tabWriter(2)
+	for _, l := range arrayLayers {
+		fmt.Fprintf(w, "\tLayer %v", l)
+	}
+	fmt.Fprintln(w)
+	for _, m := range mips {
+		switch m {
+		case mipTailRow:
+			fmt.Fprint(w, "Mip Tail")
+		case metadataRow:
+			fmt.Fprint(w, "Metadata")
+		default:
+			fmt.Fprintf(w, "Mip %v", m)
+		}
+		for _, l := range arrayLayers {
+			if size, ok := cells[layerCell{m, l}]; ok {
+				fmt.Fprintf(w, "\t%v", verb.bytes(size))
+			} else {
+				fmt.Fprint(w, "\t-")
+			}
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// sortedKeys returns the keys of the set in increasing order.
+func sortedKeys(set map[uint32]bool) []uint32 {
+	out := make([]uint32, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
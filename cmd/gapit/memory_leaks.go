@@ -0,0 +1,121 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+)
+
+// The JSON representation of the leaks printed with -leaks -json.
+type (
+	leaksJSON struct {
+		From        []uint64   `json:"from"`
+		To          []uint64   `json:"to"`
+		LeakCount   int        `json:"leakCount"`
+		LeakedBytes uint64     `json:"leakedBytes"`
+		Leaks       []leakJSON `json:"leaks"`
+	}
+	leakJSON struct {
+		ID         string `json:"id,omitempty"`
+		Name       string `json:"name"`
+		Handle     uint64 `json:"handle"`
+		Device     uint64 `json:"device"`
+		MemoryType uint32 `json:"memoryType"`
+		Size       uint64 `json:"size"`
+		Bindings   int    `json:"bindings"`
+	}
+)
+
+// findLeaks returns the allocations that are live in the to breakdown, but
+// were not yet allocated in the from breakdown, largest first. Allocations are
+// matched by their identity, see allocationID, and allocations of equal size
+// are sorted by handle.
+func findLeaks(from, to *api.MemoryBreakdown) []*api.MemoryAllocation {
+	leaks := append([]*api.MemoryAllocation{}, diffMemory(from, to).added...)
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].Size != leaks[j].Size {
+			return leaks[i].Size > leaks[j].Size
+		}
+		return leaks[i].Handle < leaks[j].Handle
+	})
+	return leaks
+}
+
+// printMemoryLeaks prints the allocations created after the from snapshot that
+// are still live in the to snapshot, as text or, with -json, as JSON. With
+// -top, only the N largest leaks are printed, but the count and total are of
+// all the leaks.
+func (verb *memoryVerb) printMemoryLeaks(ctx context.Context, from, to memorySnapshot) error {
+	leaks := findLeaks(from.mem, to.mem)
+	count, total := len(leaks), uint64(0)
+	for _, alloc := range leaks {
+		total += alloc.Size
+	}
+	if verb.Top > 0 && len(leaks) > verb.Top {
+		leaks = leaks[:verb.Top]
+	}
+
+	if verb.Json {
+		out := leaksJSON{
+			From:        from.cmd.Indices,
+			To:          to.cmd.Indices,
+			LeakCount:   count,
+			LeakedBytes: total,
+			Leaks:       make([]leakJSON, len(leaks)),
+		}
+		for i, alloc := range leaks {
+			out.Leaks[i] = leakJSON{
+				Name:       alloc.Name,
+				Handle:     alloc.Handle,
+				Device:     alloc.Device,
+				MemoryType: alloc.MemoryType,
+				Size:       alloc.Size,
+				Bindings:   len(alloc.Bindings),
+			}
+			if verb.Alloc.ID {
+				out.Leaks[i].ID = allocationID(alloc)
+			}
+		}
+		jsonBytes, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return log.Err(ctx, err, "Couldn't marshal memory leaks to JSON")
+		}
+		fmt.Fprintln(verb.out, string(jsonBytes))
+		return nil
+	}
+
+	w := verb.tabWriter(1)
+	fmt.Fprintf(w, "Allocations live at command %v created since command %v\n", to.cmd.Indices, from.cmd.Indices)
+	fmt.Fprintf(w, "%v possible leaks, %v total\n", count, verb.bytes(total))
+	if len(leaks) > 0 && verb.Alloc.ID {
+		fmt.Fprintln(w, "Name\tID\tHandle\tSize\tBindings")
+	} else if len(leaks) > 0 {
+		fmt.Fprintln(w, "Name\tHandle\tSize\tBindings")
+	}
+	for _, alloc := range leaks {
+		if verb.Alloc.ID {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", alloc.Name, allocationID(alloc), verb.handle(alloc.Handle), verb.bytes(alloc.Size), len(alloc.Bindings))
+		} else {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", alloc.Name, verb.handle(alloc.Handle), verb.bytes(alloc.Size), len(alloc.Bindings))
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/gapis/api"
+)
+
+// The exit codes of the memory verb when a limit is exceeded. Failing to load
+// the capture exits with 1, and invalid flags exit with 2.
+const (
+	memoryBudgetExit app.ExitCode = 3
+	memoryAliasExit  app.ExitCode = 4
+)
+
+// limitFailure is a limit set by -fail-over or -fail-on-alias that was
+// exceeded at one of the commands.
+type limitFailure struct {
+	code   app.ExitCode
+	report string
+}
+
+// checkLimits returns the failures of the snapshots against -fail-over and
+// -fail-on-alias. It must be called before -top drops any allocation.
+func (verb *memoryVerb) checkLimits(snapshots []memorySnapshot) []limitFailure {
+	failures := []limitFailure{}
+	for _, snapshot := range snapshots {
+		allocs := snapshot.mem.Allocations
+		if budget := uint64(verb.Fail.Over); budget > 0 {
+			if report, over := verb.checkBudget(allocs, budget); over {
+				failures = append(failures, limitFailure{memoryBudgetExit,
+					fmt.Sprintf("%v %v", snapshot.header(), report)})
+			}
+		}
+		if verb.Fail.On.Alias {
+			if report, aliased := checkHardAliases(allocs); aliased {
+				failures = append(failures, limitFailure{memoryAliasExit,
+					fmt.Sprintf("%v has hard aliased regions:\n%v", snapshot.header(), report)})
+			}
+		}
+	}
+	return failures
+}
+
+// reportLimits returns an app.ExitError describing the failures, or nil if
+// there are none. The exit code of -fail-over takes precedence over the one of
+// -fail-on-alias, which takes precedence over the one of -assert.
+func reportLimits(failures []limitFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+	code := failures[0].code
+	reports := make([]string, len(failures))
+	for i, f := range failures {
+		if f.code < code {
+			code = f.code
+		}
+		reports[i] = f.report
+	}
+	return app.ExitError{Code: code, Err: errors.New(strings.Join(reports, "\n"))}
+}
+
+// checkBudget returns whether the total size of the allocations is over the
+// budget. If so, the report lists the largest allocations that would need to
+// be freed to be within budget.
+func (verb *memoryVerb) checkBudget(allocs []*api.MemoryAllocation, budget uint64) (string, bool) {
+	total := uint64(0)
+	for _, alloc := range allocs {
+		total += alloc.Size
+	}
+	if total <= budget {
+		return "", false
+	}
+
+	largest := append([]*api.MemoryAllocation{}, allocs...)
+	sort.SliceStable(largest, func(i, j int) bool { return largest[i].Size > largest[j].Size })
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "is over budget: %v allocated, budget %v. Largest allocations over budget:\n",
+		verb.bytes(total), verb.bytes(budget))
+	for _, alloc := range largest {
+		if total <= budget {
+			break
+		}
+		fmt.Fprintf(sb, "\t%v: %v\n", alloc.Name, verb.bytes(alloc.Size))
+		total -= alloc.Size
+	}
+	return sb.String(), true
+}
+
+// checkHardAliases returns whether any of the allocations has a hard aliased
+// region, along with the list of such allocations.
+func checkHardAliases(allocs []*api.MemoryAllocation) (string, bool) {
+	sb := &strings.Builder{}
+	for _, alloc := range allocs {
+		hard := 0
+		for _, a := range alloc.Aliases {
+			if !a.Sparse {
+				hard++
+			}
+		}
+		if hard > 0 {
+			fmt.Fprintf(sb, "\t%v: %v hard aliased regions\n", alloc.Name, hard)
+		}
+	}
+	return sb.String(), sb.Len() > 0
+}
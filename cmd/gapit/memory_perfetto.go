@@ -0,0 +1,81 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/google/gapid/core/log"
+)
+
+// The Chrome trace JSON written with -perfetto. Each sample is a set of
+// counter events, with the command index as the timestamp.
+type (
+	traceJSON struct {
+		TraceEvents []traceEventJSON `json:"traceEvents"`
+	}
+	traceEventJSON struct {
+		Name  string            `json:"name"`
+		Phase string            `json:"ph"`
+		Ts    uint64            `json:"ts"`
+		Pid   int               `json:"pid"`
+		Args  map[string]uint64 `json:"args"`
+	}
+)
+
+// memoryTrace returns the counter tracks of the samples: the total allocated
+// and mapped bytes, and the total bytes of each memory type.
+func memoryTrace(samples []memorySample) traceJSON {
+	trace := traceJSON{TraceEvents: []traceEventJSON{}}
+	for _, s := range samples {
+		trace.TraceEvents = append(trace.TraceEvents, traceEventJSON{
+			Name:  "Memory",
+			Phase: "C",
+			Ts:    s.command,
+			Args:  map[string]uint64{"allocated": s.total, "mapped": s.mapped},
+		})
+		types := make([]uint32, 0, len(s.types))
+		for t := range s.types {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(i, j int) bool { return types[i] < types[j] })
+		for _, t := range types {
+			trace.TraceEvents = append(trace.TraceEvents, traceEventJSON{
+				Name:  fmt.Sprintf("Memory Type %v", t),
+				Phase: "C",
+				Ts:    s.command,
+				Args:  map[string]uint64{"allocated": s.types[t]},
+			})
+		}
+	}
+	return trace
+}
+
+// writeMemoryTrace writes the counter tracks of the samples to the file as
+// Chrome trace JSON, which can be opened in Perfetto.
+func writeMemoryTrace(ctx context.Context, file string, samples []memorySample) error {
+	data, err := json.Marshal(memoryTrace(samples))
+	if err != nil {
+		return log.Err(ctx, err, "Couldn't marshal memory samples to JSON")
+	}
+	if err := ioutil.WriteFile(file, data, 0644); err != nil {
+		return log.Errf(ctx, err, "Failed to write the memory trace to %v", file)
+	}
+	return nil
+}
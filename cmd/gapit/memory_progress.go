@@ -0,0 +1,153 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/gapid/core/app/crash"
+	"github.com/google/gapid/core/app/status"
+	"github.com/google/gapid/core/event/task"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// progressInterval is the time between the messages printed while GAPIS is
+// resolving a memory breakdown.
+const progressInterval = 2 * time.Second
+
+// fetchWithProgress fetches the memory breakdown after the command cmd, the
+// i-th of count samples, in a status task. While the request is outstanding, a
+// message is printed to stderr every progressInterval, so stdout only holds
+// the report. If ctx is cancelled, the request is aborted.
+func fetchWithProgress(ctx context.Context, client service.Service, cmd *path.Command, i, count int) (*api.MemoryBreakdown, error) {
+	status.UpdateProgress(ctx, uint64(i), uint64(count))
+	ctx = status.Start(ctx, "Memory breakdown at %v", cmd.Indices)
+	defer status.Finish(ctx)
+
+	counter := ""
+	if count > 1 {
+		counter = fmt.Sprintf(" (%v/%v)", i+1, count)
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		start := time.Now()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-task.ShouldStop(ctx):
+				return
+			case <-ticker.C:
+				fmt.Fprintf(os.Stderr, "Still resolving the memory breakdown at command %v%v... %v\n",
+					cmd.Indices, counter, time.Since(start).Round(time.Second))
+			}
+		}
+	}()
+	var mem *api.MemoryBreakdown
+	err := cancellable(ctx, func() error {
+		var err error
+		mem, err = breakdown.Fetch(ctx, client, cmd)
+		return err
+	})
+	return mem, err
+}
+
+// fetchEach fetches the memory breakdowns after each of the commands, with at
+// most -concurrency requests outstanding, and calls f with the index of the
+// command and its breakdown. The calls to f are serialized, but are in the
+// order the requests complete. The first error cancels the other requests, and
+// is returned once all the workers are done.
+func (verb *memoryVerb) fetchEach(ctx context.Context, client service.Service, cmds []*path.Command, f func(i int, mem *api.MemoryBreakdown)) error {
+	workers := verb.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := task.WithCancel(ctx)
+	defer cancel()
+
+	var mutex sync.Mutex
+	var firstErr error
+	fetched := 0
+	next := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < workers && w < len(cmds); w++ {
+		wg.Add(1)
+		crash.Go(func() {
+			defer wg.Done()
+			for i := range next {
+				mem, err := fetchWithProgress(ctx, client, cmds[i], i, len(cmds))
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
+				} else if err == nil && firstErr == nil {
+					f(i, mem)
+					fetched++
+				}
+				mutex.Unlock()
+			}
+		})
+	}
+
+feed:
+	for i := range cmds {
+		select {
+		case next <- i:
+		case <-task.ShouldStop(ctx):
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+
+	if firstErr == nil && fetched < len(cmds) {
+		return cancelled(ctx)
+	}
+	return firstErr
+}
+
+// cancellable calls f, but returns as soon as ctx is cancelled, without
+// waiting for f to complete. As the GAPIS requests made by f use ctx, they are
+// aborted along with it.
+func cancellable(ctx context.Context, f func() error) error {
+	if task.Stopped(ctx) {
+		return cancelled(ctx)
+	}
+	done := make(chan error, 1)
+	crash.Go(func() { done <- f() })
+	select {
+	case err := <-done:
+		return err
+	case <-task.ShouldStop(ctx):
+		return cancelled(ctx)
+	}
+}
+
+// cancelled logs that the operation was cancelled, and returns the reason.
+func cancelled(ctx context.Context) error {
+	log.W(ctx, "Memory breakdown operation cancelled")
+	return task.StopReason(ctx)
+}
@@ -0,0 +1,81 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// resourceUsage is the total of the bindings of a single resource, across all
+// the allocations.
+type resourceUsage struct {
+	handle      uint64
+	name        string
+	bindings    int
+	allocations int
+	footprint   uint64
+}
+
+// summarizeResources returns the usage of each resource bound by the bindings
+// that pass the filter, sorted by descending footprint, then by handle.
+func summarizeResources(allocs []*api.MemoryAllocation, filter allocationFilter) []resourceUsage {
+	usages := map[uint64]*resourceUsage{}
+	for _, alloc := range allocs {
+		seen := map[uint64]bool{}
+		for _, b := range filter.bindings(alloc.Bindings) {
+			u, ok := usages[b.Handle]
+			if !ok {
+				u = &resourceUsage{handle: b.Handle, name: b.Name}
+				usages[b.Handle] = u
+			}
+			u.bindings++
+			u.footprint += b.Size
+			if !seen[b.Handle] {
+				seen[b.Handle] = true
+				u.allocations++
+			}
+		}
+	}
+	out := make([]resourceUsage, 0, len(usages))
+	for _, u := range usages {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].footprint != out[j].footprint {
+			return out[i].footprint > out[j].footprint
+		}
+		return out[i].handle < out[j].handle
+	})
+	return out
+}
+
+// printResourceSummary prints the number of bindings, allocations and bytes
+// bound of each resource, as a resource centric counterpart of the
+// allocations.
+func (verb *memoryVerb) printResourceSummary(mem *api.MemoryBreakdown, filter allocationFilter) {
+	usages := summarizeResources(mem.Allocations, filter)
+	w := verb.tabWriter(1)
+	fmt.Fprintf(w, "%v bound resources\n", len(usages))
+	if len(usages) > 0 {
+		fmt.Fprintln(w, "Handle\tName\tBindings\tAllocations\tFootprint")
+	}
+	for _, u := range usages {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", verb.handle(u.handle), u.name, u.bindings, u.allocations, verb.bytes(u.footprint))
+	}
+	w.Flush()
+}
@@ -0,0 +1,173 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// allocationKeys are the comparators selectable with -sort. An empty key sorts
+// by handle.
+var allocationKeys = map[string]func(a, b *api.MemoryAllocation) bool{
+	"handle":   func(a, b *api.MemoryAllocation) bool { return a.Handle < b.Handle },
+	"size":     func(a, b *api.MemoryAllocation) bool { return a.Size < b.Size },
+	"name":     func(a, b *api.MemoryAllocation) bool { return a.Name < b.Name },
+	"type":     func(a, b *api.MemoryAllocation) bool { return a.MemoryType < b.MemoryType },
+	"bindings": func(a, b *api.MemoryAllocation) bool { return len(a.Bindings) < len(b.Bindings) },
+}
+
+// bindingKeys are the comparators selectable with -sort-bindings. An empty key
+// sorts by offset, then size, then handle.
+var bindingKeys = map[string]func(a, b *api.MemoryBinding) bool{
+	"offset": func(a, b *api.MemoryBinding) bool { return breakdown.Bindings{a, b}.Less(0, 1) },
+	"size":   func(a, b *api.MemoryBinding) bool { return a.Size < b.Size },
+	"handle": func(a, b *api.MemoryBinding) bool { return a.Handle < b.Handle },
+	"name":   func(a, b *api.MemoryBinding) bool { return a.Name < b.Name },
+	"type":   func(a, b *api.MemoryBinding) bool { return breakdown.BindingTypeName(a) < breakdown.BindingTypeName(b) },
+}
+
+// sharerKeys are the comparators selectable with -sort-sharers, given the names
+// of the sharers. An empty key sorts by handle.
+var sharerKeys = map[string]func(a, b uint64, names map[uint64]string) bool{
+	"handle": func(a, b uint64, names map[uint64]string) bool { return a < b },
+	"name": func(a, b uint64, names map[uint64]string) bool {
+		if names[a] != names[b] {
+			return names[a] < names[b]
+		}
+		return a < b
+	},
+}
+
+// sortKey is one of the keys of a compound -sort specification, e.g. the
+// size:desc of size:desc,name:asc.
+type sortKey struct {
+	name string
+	desc bool
+}
+
+// parseSortKeys parses a compound -sort specification, a comma separated list
+// of allocation keys, each optionally followed by :asc or :desc. Keys without
+// a direction are descending if desc is set.
+func parseSortKeys(spec string, desc bool) ([]sortKey, error) {
+	keys := []sortKey{}
+	if spec == "" {
+		return keys, nil
+	}
+	for _, s := range strings.Split(spec, ",") {
+		key := sortKey{name: strings.TrimSpace(s), desc: desc}
+		if i := strings.Index(key.name, ":"); i >= 0 {
+			switch dir := key.name[i+1:]; dir {
+			case "asc":
+				key.desc = false
+			case "desc":
+				key.desc = true
+			default:
+				return nil, fmt.Errorf("Unknown -sort direction %q, expected asc or desc", dir)
+			}
+			key.name = key.name[:i]
+		}
+		if _, ok := allocationKeys[key.name]; !ok {
+			names := []string{}
+			for k := range allocationKeys {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("Unknown -sort key %q, expected one of %v", key.name, strings.Join(names, ", "))
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// checkSortKeys returns an error if -sort, -sort-bindings or -sort-sharers are
+// not known keys.
+func (verb *memoryVerb) checkSortKeys() error {
+	if _, err := parseSortKeys(verb.Sort, verb.Desc); err != nil {
+		return err
+	}
+	if _, ok := bindingKeys[verb.SortBindings]; verb.SortBindings != "" && !ok {
+		keys := []string{}
+		for k := range bindingKeys {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("Unknown -sort-bindings key %q, expected one of %v", verb.SortBindings, strings.Join(keys, ", "))
+	}
+	if _, ok := sharerKeys[verb.SortSharers]; verb.SortSharers != "" && !ok {
+		return fmt.Errorf("Unknown -sort-sharers key %q, expected handle or name", verb.SortSharers)
+	}
+	return nil
+}
+
+// sortAllocations sorts the allocations by the -sort keys, each breaking the
+// ties of the previous one. Allocations with equal keys are sorted by handle.
+func (verb *memoryVerb) sortAllocations(allocs []*api.MemoryAllocation) {
+	sort.Slice(allocs, func(i, j int) bool {
+		return allocs[i].Handle < allocs[j].Handle
+	})
+	keys, err := parseSortKeys(verb.Sort, verb.Desc)
+	if err != nil || len(keys) == 0 {
+		keys = []sortKey{{"handle", verb.Desc}}
+	}
+	sort.SliceStable(allocs, func(i, j int) bool {
+		for _, key := range keys {
+			less, a, b := allocationKeys[key.name], allocs[i], allocs[j]
+			if key.desc {
+				a, b = b, a
+			}
+			if less(a, b) {
+				return true
+			}
+			if less(b, a) {
+				return false
+			}
+		}
+		return false
+	})
+}
+
+// sortBindings returns the bindings sorted by the -sort-bindings key. The
+// bindings must already be sorted by breakdown.Bindings.Less, which breaks the ties.
+func (verb *memoryVerb) sortBindings(bindings breakdown.Bindings) breakdown.Bindings {
+	less, ok := bindingKeys[verb.SortBindings]
+	if !ok {
+		return bindings
+	}
+	sorted := append(breakdown.Bindings{}, bindings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j])
+	})
+	return sorted
+}
+
+// sortSharers returns the handles of the sharers of an aliased region sorted
+// by the -sort-sharers key. The sharers are already sorted by handle, so they
+// are returned as is without -sort-sharers.
+func (verb *memoryVerb) sortSharers(sharers []uint64, names map[uint64]string) []uint64 {
+	less, ok := sharerKeys[verb.SortSharers]
+	if !ok {
+		return sharers
+	}
+	sorted := append([]uint64{}, sharers...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return less(sorted[i], sorted[j], names)
+	})
+	return sorted
+}
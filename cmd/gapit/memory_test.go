@@ -0,0 +1,935 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/gapid/core/app/flags"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestHumanBytes(t *testing.T) {
+	assert := assert.To(t)
+
+	for _, test := range []struct {
+		bytes  uint64
+		expect string
+	}{
+		{0, "0 B"},
+		{1, "1 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{1024*1024 - 1, "1024.0 KiB"},
+		{1024 * 1024, "1.0 MiB"},
+		{256 * 1024 * 1024, "256.0 MiB"},
+		{2 * 1024 * 1024 * 1024, "2.0 GiB"},
+		{1 << 40, "1.0 TiB"},
+		{1 << 63, "8.0 EiB"},
+	} {
+		assert.For("humanBytes(%v)", test.bytes).That(humanBytes(test.bytes)).Equals(test.expect)
+	}
+}
+
+func TestGroupByMemoryType(t *testing.T) {
+	assert := assert.To(t)
+	a := &api.MemoryAllocation{Handle: 1, MemoryType: 2}
+	b := &api.MemoryAllocation{Handle: 2, MemoryType: 0}
+	c := &api.MemoryAllocation{Handle: 3, MemoryType: 2}
+	groups := groupByMemoryType([]*api.MemoryAllocation{a, b, c})
+	assert.For("groups").That(len(groups)).Equals(2)
+	assert.For("first type").That(groups[0].memoryType).Equals(uint32(0))
+	assert.For("first").ThatSlice(groups[0].allocations).Equals([]*api.MemoryAllocation{b})
+	assert.For("second type").That(groups[1].memoryType).Equals(uint32(2))
+	assert.For("second").ThatSlice(groups[1].allocations).Equals([]*api.MemoryAllocation{a, c})
+}
+
+func TestSortAllocations(t *testing.T) {
+	assert := assert.To(t)
+	a := &api.MemoryAllocation{Handle: 3, Size: 10}
+	b := &api.MemoryAllocation{Handle: 1, Size: 20}
+	c := &api.MemoryAllocation{Handle: 2, Size: 10}
+	allocs := []*api.MemoryAllocation{a, b, c}
+
+	(&memoryVerb{}).sortAllocations(allocs)
+	assert.For("handle").ThatSlice(allocs).Equals([]*api.MemoryAllocation{b, c, a})
+
+	verb := &memoryVerb{}
+	verb.Sort, verb.Desc = "size", true
+	verb.sortAllocations(allocs)
+	assert.For("size desc").ThatSlice(allocs).Equals([]*api.MemoryAllocation{b, c, a})
+
+	verb.Desc = false
+	verb.sortAllocations(allocs)
+	assert.For("size").ThatSlice(allocs).Equals([]*api.MemoryAllocation{c, a, b})
+}
+
+func TestSparseGrid(t *testing.T) {
+	assert := assert.To(t)
+	blocks := []*api.SparseImageBlock{
+		{XOffset: 0, YOffset: 0, Width: 64, Height: 64},
+		{XOffset: 128, YOffset: 0, Width: 32, Height: 64},
+		{XOffset: 64, YOffset: 64, Width: 64, Height: 32},
+	}
+	assert.For("grid").ThatSlice(sparseGrid(blocks)).Equals([]string{"#.#", ".#."})
+
+	allocs := []*api.MemoryAllocation{{Bindings: []*api.MemoryBinding{{Handle: 26, Name: "tex", Size: 64,
+		Type: &api.MemoryBinding_SparseImageBlock{SparseImageBlock: blocks[0]}}}}}
+	buf := &bytes.Buffer{}
+	verb := &memoryVerb{}
+	verb.printSparseGrids(buf, allocs)
+	assert.For("handle").ThatString(buf.String()).Contains("    Handle: 26\n")
+	buf.Reset()
+	verb.Hex.Handles = true
+	verb.printSparseGrids(buf, allocs)
+	assert.For("hex handle").ThatString(buf.String()).Contains("    Handle: 0x1a\n")
+}
+
+func TestMemoryTypeName(t *testing.T) {
+	assert := assert.To(t)
+	flags := &service.ConstantSet{
+		Constants: []*service.Constant{
+			{Name: "DEVICE_LOCAL", Value: 1},
+			{Name: "HOST_VISIBLE", Value: 2},
+		},
+		IsBitfield: true,
+	}
+	alloc := &api.MemoryAllocation{MemoryType: 2, Flags: 3}
+	assert.For("flags").That(memoryTypeName(alloc, breakdown.NewFlagNameCache(flags))).Equals("2 (DEVICE_LOCAL | HOST_VISIBLE)")
+	assert.For("no constants").That(memoryTypeName(alloc, breakdown.NewFlagNameCache(nil))).Equals("2")
+
+	alloc.Mapping = &api.MemoryMapping{}
+	buf := &bytes.Buffer{}
+	verb := &memoryVerb{MemoryFlags: MemoryFlags{Color: "never"}}
+	verb.No.Bindings = true
+	verb.printAllocation(buf, alloc, flags, allocationFilter{})
+	assert.For("printed once").That(strings.Count(buf.String(), "HOST_VISIBLE")).Equals(1)
+	assert.For("no flags block").ThatString(buf.String()).DoesNotContain("Flags:")
+	buf.Reset()
+	verb.printAllocation(buf, alloc, nil, allocationFilter{})
+	assert.For("unknown names").ThatString(buf.String()).Contains("Flags: \t0x3\n")
+
+	alloc.Flags = 0
+	assert.For("no flags").That(memoryTypeName(alloc, breakdown.NewFlagNameCache(flags))).Equals("2")
+}
+
+func TestPrintMemoryFormat(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+	verb := &memoryVerb{}
+	verb.Format = "{{.Allocation.Name}} {{hex .Allocation.Handle}} {{humanBytes .Allocation.Size}} {{len .Aliases}}\n"
+	tmpl, err := verb.formatTemplate()
+	assert.For("parse").ThatError(err).Succeeded()
+
+	mem := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{{
+		Name:   "mem",
+		Handle: 255,
+		Size:   2048,
+		Bindings: []*api.MemoryBinding{
+			{Handle: 1, Offset: 0, Size: 16},
+			{Handle: 2, Offset: 8, Size: 16},
+		},
+	}}}
+	breakdown.ComputeAllocationAliasing(mem)
+	buf := &bytes.Buffer{}
+	err = verb.printMemoryFormat(ctx, buf, tmpl, memorySnapshot{&path.Command{}, mem, ""}, allocationFilter{})
+	assert.For("execute").ThatError(err).Succeeded()
+	assert.For("output").ThatString(buf.String()).Equals("mem 0xff 2.0 KiB 1\n")
+}
+
+func TestSummarizeDevices(t *testing.T) {
+	assert := assert.To(t)
+	allocs := []*api.MemoryAllocation{
+		{Device: 7, Size: 10},
+		{Device: 3, Size: 20},
+		{Device: 7, Size: 30},
+	}
+	assert.For("devices").That(summarizeDevices(allocs)).DeepEquals([]deviceSummary{
+		{device: 3, count: 1, size: 20},
+		{device: 7, count: 2, size: 40},
+	})
+}
+
+func TestIsHostCoherent(t *testing.T) {
+	assert := assert.To(t)
+	flags := &service.ConstantSet{
+		Constants: []*service.Constant{
+			{Name: "VK_MEMORY_PROPERTY_HOST_VISIBLE_BIT", Value: 2},
+			{Name: "VK_MEMORY_PROPERTY_HOST_COHERENT_BIT", Value: 4},
+		},
+		IsBitfield: true,
+	}
+	for _, test := range []struct {
+		name            string
+		flags           *service.ConstantSet
+		allocFlags      uint32
+		coherent, known bool
+	}{
+		{"coherent", flags, 6, true, true},
+		{"non-coherent", flags, 2, false, true},
+		{"no constants", nil, 6, false, false},
+		{"no coherent constant", &service.ConstantSet{IsBitfield: true}, 6, false, false},
+	} {
+		coherent, known := isHostCoherent(&api.MemoryAllocation{Flags: test.allocFlags}, test.flags)
+		assert.For("%v coherent", test.name).That(coherent).Equals(test.coherent)
+		assert.For("%v known", test.name).That(known).Equals(test.known)
+	}
+}
+
+func TestMemoryDiffGrowth(t *testing.T) {
+	assert := assert.To(t)
+	added := &api.MemoryAllocation{Handle: 1, Size: 10}
+	shrunk := allocationDiff{
+		old:             &api.MemoryAllocation{Handle: 2, Size: 20},
+		new:             &api.MemoryAllocation{Handle: 2, Size: 10},
+		removedBindings: []*api.MemoryBinding{{Handle: 5}},
+	}
+	grown := allocationDiff{
+		old:             &api.MemoryAllocation{Handle: 3, Size: 10},
+		new:             &api.MemoryAllocation{Handle: 3, Size: 20},
+		removedBindings: []*api.MemoryBinding{{Handle: 6}},
+	}
+	diff := memoryDiff{
+		added:   []*api.MemoryAllocation{added},
+		removed: []*api.MemoryAllocation{{Handle: 4}},
+		changed: []allocationDiff{shrunk, grown},
+	}.growth()
+	assert.For("added").ThatSlice(diff.added).Equals([]*api.MemoryAllocation{added})
+	assert.For("removed").ThatSlice(diff.removed).IsEmpty()
+	assert.For("changed").That(len(diff.changed)).Equals(1)
+	assert.For("grown").That(diff.changed[0].new).Equals(grown.new)
+	assert.For("removed bindings").ThatSlice(diff.changed[0].removedBindings).IsEmpty()
+}
+
+func TestResourceFilter(t *testing.T) {
+	assert := assert.To(t)
+	verb := &memoryVerb{}
+	verb.Resource = "0x10"
+	filter, err := verb.allocationFilter()
+	assert.For("err").ThatError(err).Succeeded()
+
+	b1 := &api.MemoryBinding{Handle: 0x10, Offset: 0, Size: 8}
+	b2 := &api.MemoryBinding{Handle: 0x20, Offset: 4, Size: 8}
+	b3 := &api.MemoryBinding{Handle: 0x30, Offset: 16, Size: 8}
+	bound := &api.MemoryAllocation{Handle: 1, Bindings: []*api.MemoryBinding{b1, b2}}
+	other := &api.MemoryAllocation{Handle: 2, Bindings: []*api.MemoryBinding{b3}}
+	assert.For("bound").That(filter.keep(bound)).Equals(true)
+	assert.For("other").That(filter.keep(other)).Equals(false)
+	assert.For("bindings").ThatSlice(filter.bindings(bound.Bindings)).Equals([]*api.MemoryBinding{b1})
+
+	aliases := []breakdown.Alias{
+		{Offset: 4, Size: 4, Sharers: []uint64{0x10, 0x20}},
+		{Offset: 20, Size: 4, Sharers: []uint64{0x20, 0x30}},
+	}
+	assert.For("aliases").That(filter.aliases(aliases, nil)).DeepEquals(aliases[:1])
+}
+
+func TestFindLeaks(t *testing.T) {
+	assert := assert.To(t)
+	kept := &api.MemoryAllocation{Handle: 1, Size: 100}
+	small := &api.MemoryAllocation{Handle: 4, Size: 10}
+	large := &api.MemoryAllocation{Handle: 3, Size: 30}
+	tied := &api.MemoryAllocation{Handle: 2, Size: 10}
+	from := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{kept, {Handle: 5, Size: 50}}}
+	to := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{kept, small, large, tied}}
+	assert.For("leaks").ThatSlice(findLeaks(from, to)).Equals([]*api.MemoryAllocation{large, tied, small})
+}
+
+func TestAspectListFormat(t *testing.T) {
+	assert := assert.To(t)
+	l := aspectList{api.AspectType_COLOR, api.AspectType(42), api.AspectType_STENCIL}
+	assert.For("format").That(fmt.Sprint(l)).Equals("Color, Aspect(42), Stencil")
+}
+
+func TestBindingAspects(t *testing.T) {
+	assert := assert.To(t)
+	depthStencil := []api.AspectType{api.AspectType_DEPTH, api.AspectType_STENCIL}
+	for _, test := range []struct {
+		name     string
+		binding  *api.MemoryBinding
+		expected string
+	}{
+		{"buffer", &api.MemoryBinding{Type: &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}}, ""},
+		{"image", &api.MemoryBinding{Type: &api.MemoryBinding_Image{Image: &api.NormalBinding{}}}, ""},
+		{"sparse image block", &api.MemoryBinding{Type: &api.MemoryBinding_SparseImageBlock{
+			SparseImageBlock: &api.SparseImageBlock{Aspects: depthStencil}}}, "Depth, Stencil"},
+		{"sparse image metadata", &api.MemoryBinding{Type: &api.MemoryBinding_SparseImageMetadata{
+			SparseImageMetadata: &api.SparseImageMetadataMipTail{Aspects: []api.AspectType{api.AspectType_COLOR}}}}, "Color"},
+		{"sparse image mip tail", &api.MemoryBinding{Type: &api.MemoryBinding_SparseImageMipTail{
+			SparseImageMipTail: &api.SparseImageMetadataMipTail{Aspects: depthStencil}}}, "Depth, Stencil"},
+		{"sparse opaque image block", &api.MemoryBinding{Type: &api.MemoryBinding_SparseOpaqueImageBlock{
+			SparseOpaqueImageBlock: &api.SparseBinding{}}}, ""},
+		{"sparse buffer block", &api.MemoryBinding{Type: &api.MemoryBinding_SparseBufferBlock{
+			SparseBufferBlock: &api.SparseBinding{}}}, ""},
+	} {
+		assert.For(test.name).That(fmt.Sprint(bindingAspects(test.binding))).Equals(test.expected)
+
+		buf := &bytes.Buffer{}
+		verb := &memoryVerb{}
+		verb.printBindings(buf, breakdown.Bindings{test.binding}, nil)
+		printed := strings.Contains(buf.String(), "Aspects: \t"+test.expected+"\n")
+		assert.For("%v printed", test.name).That(printed).Equals(test.expected != "")
+
+		data, err := json.Marshal(newBindingJSON(test.binding))
+		assert.For("%v json", test.name).ThatError(err).Succeeded()
+		assert.For("%v json aspects", test.name).That(strings.Contains(string(data), `"aspects"`)).Equals(test.expected != "")
+	}
+}
+
+func TestBindingHistogram(t *testing.T) {
+	assert := assert.To(t)
+	bindings := []*api.MemoryBinding{
+		{Size: 40, Type: &api.MemoryBinding_SparseBufferBlock{}},
+		{Size: 10, Type: &api.MemoryBinding_Buffer{}},
+		{Size: 30, Type: &api.MemoryBinding_SparseBufferBlock{}},
+		{Size: 20, Type: &api.MemoryBinding_Image{}},
+	}
+	assert.For("histogram").That(newBindingHistogram(bindings)).DeepEquals(bindingHistogram{
+		types: []bindingTypeCount{
+			{"Buffer", 1},
+			{"Image", 1},
+			{"Sparse Buffer Block", 2},
+		},
+		min: 10, median: 20, max: 40,
+	})
+}
+
+func TestCommandPoint(t *testing.T) {
+	assert := assert.To(t)
+	p := CommandPoint{}
+	assert.For("index").ThatError(p.Set("42")).Succeeded()
+	assert.For("index").That(p).DeepEquals(CommandPoint{Indices: flags.U64Slice{42}})
+	assert.For("subcommand").ThatError(p.Set("[4,2]")).Succeeded()
+	assert.For("subcommand").That(p).DeepEquals(CommandPoint{Indices: flags.U64Slice{4, 2}})
+	assert.For("frame").ThatError(p.Set("frame:3")).Succeeded()
+	assert.For("frame").That(p).DeepEquals(CommandPoint{Label: "frame:3"})
+	assert.For("draw").ThatError(p.Set("draw:Shadow Pass")).Succeeded()
+	assert.For("draw").That(p).DeepEquals(CommandPoint{Label: "draw:Shadow Pass"})
+	assert.For("last").ThatError(p.Set("last")).Succeeded()
+	assert.For("last").That(p).DeepEquals(CommandPoint{Label: "last"})
+	assert.For("first").ThatError(p.Set("first")).Succeeded()
+	assert.For("first").That(p).DeepEquals(CommandPoint{Label: "first"})
+	assert.For("unknown").ThatError(p.Set("marker:3")).Failed()
+}
+
+func TestResolveCommandPointKeywords(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+	first, err := resolveCommandPoint(ctx, nil, nil, 10, CommandPoint{Label: "first"})
+	assert.For("first").ThatError(err).Succeeded()
+	assert.For("first").ThatSlice(first).Equals(flags.U64Slice{0})
+	last, err := resolveCommandPoint(ctx, nil, nil, 10, CommandPoint{Label: "last"})
+	assert.For("last").ThatError(err).Succeeded()
+	assert.For("last").ThatSlice(last).Equals(flags.U64Slice{9})
+	_, err = resolveCommandPoint(ctx, nil, nil, 0, CommandPoint{Label: "last"})
+	assert.For("no commands").ThatError(err).Failed()
+}
+
+func TestSummarizeMemory(t *testing.T) {
+	assert := assert.To(t)
+	mem := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{{
+		Handle:  1,
+		Size:    64,
+		Mapping: &api.MemoryMapping{Size: 32},
+		Bindings: []*api.MemoryBinding{
+			{Handle: 1, Offset: 0, Size: 16},
+			{Handle: 2, Offset: 8, Size: 16},
+		},
+	}, {
+		Handle:   2,
+		Size:     128,
+		Bindings: []*api.MemoryBinding{{Handle: 3, Offset: 0, Size: 4}},
+	}}}
+	breakdown.ComputeAllocationAliasing(mem)
+	assert.For("summary").That(summarizeMemory(mem.Allocations, allocationFilter{})).Equals(memorySummary{
+		allocations: 2,
+		bindings:    3,
+		allocated:   192,
+		mapped:      32,
+		bound:       36,
+		aliased:     8,
+	})
+}
+
+func TestUnusedAllocations(t *testing.T) {
+	assert := assert.To(t)
+	none := &api.MemoryAllocation{Handle: 1}
+	empty := &api.MemoryAllocation{Handle: 2, Bindings: []*api.MemoryBinding{{Handle: 5, Size: 0}}}
+	bound := &api.MemoryAllocation{Handle: 3, Bindings: []*api.MemoryBinding{{Handle: 6, Size: 8}}}
+	assert.For("unused").ThatSlice(unusedAllocations([]*api.MemoryAllocation{none, empty, bound})).
+		Equals([]*api.MemoryAllocation{none, empty})
+}
+
+func TestFindResource(t *testing.T) {
+	assert := assert.To(t)
+	b1 := &api.MemoryBinding{Handle: 7, Offset: 64, Size: 8}
+	b2 := &api.MemoryBinding{Handle: 7, Offset: 0, Size: 8}
+	b3 := &api.MemoryBinding{Handle: 7, Offset: 16, Size: 8}
+	a := &api.MemoryAllocation{Handle: 2, Bindings: []*api.MemoryBinding{b1, {Handle: 8, Offset: 8, Size: 8}, b2}}
+	b := &api.MemoryAllocation{Handle: 1, Bindings: []*api.MemoryBinding{b3}}
+	assert.For("bindings").That(findResource([]*api.MemoryAllocation{a, b}, 7)).DeepEquals([]resourceBinding{
+		{b, b3},
+		{a, b2},
+		{a, b1},
+	})
+}
+
+func TestMisalignedBindings(t *testing.T) {
+	assert := assert.To(t)
+	aligned := &api.MemoryBinding{Handle: 1, Offset: 0, Size: 0x10000}
+	offset := &api.MemoryBinding{Handle: 2, Offset: 0x11000, Size: 0x10000}
+	size := &api.MemoryBinding{Handle: 3, Offset: 0x30000, Size: 0x800}
+	alloc := &api.MemoryAllocation{Bindings: []*api.MemoryBinding{size, offset, aligned}}
+	assert.For("misaligned").That(misalignedBindings([]*api.MemoryAllocation{alloc}, 0x10000, allocationFilter{})).DeepEquals([]misalignedBinding{
+		{alloc, offset, 0x1000},
+		{alloc, size, 0x800},
+	})
+	assert.For("zero").That(alignment(0, 0)).Equals(uint64(0))
+}
+
+func TestMemoryTrace(t *testing.T) {
+	assert := assert.To(t)
+	samples := []memorySample{
+		{command: 10, total: 48, mapped: 16, types: map[uint32]uint64{2: 32, 0: 16}},
+	}
+	assert.For("trace").That(memoryTrace(samples)).DeepEquals(traceJSON{TraceEvents: []traceEventJSON{
+		{Name: "Memory", Phase: "C", Ts: 10, Args: map[string]uint64{"allocated": 48, "mapped": 16}},
+		{Name: "Memory Type 0", Phase: "C", Ts: 10, Args: map[string]uint64{"allocated": 16}},
+		{Name: "Memory Type 2", Phase: "C", Ts: 10, Args: map[string]uint64{"allocated": 32}},
+	}})
+}
+
+func TestSortSharers(t *testing.T) {
+	assert := assert.To(t)
+	names := map[uint64]string{1: "b", 2: "a", 3: "b"}
+	sharers := []uint64{1, 2, 3}
+	verb := &memoryVerb{}
+	assert.For("handle").ThatSlice(verb.sortSharers(sharers, names)).Equals([]uint64{1, 2, 3})
+	verb.SortSharers = "name"
+	assert.For("name").ThatSlice(verb.sortSharers(sharers, names)).Equals([]uint64{2, 1, 3})
+	assert.For("unchanged").ThatSlice(sharers).Equals([]uint64{1, 2, 3})
+}
+
+func TestSizeDelta(t *testing.T) {
+	assert := assert.To(t)
+	verb := &memoryVerb{}
+	assert.For("grown").That(verb.sizeDelta(256<<20, 300<<20)).Equals("+44.0 MiB, +17%")
+	assert.For("shrunk").That(verb.sizeDelta(200, 150)).Equals("-50 B, -25%")
+	assert.For("unchanged").That(verb.sizeDelta(64, 64)).Equals("+0 B, +0%")
+	assert.For("from zero").That(verb.sizeDelta(0, 16)).Equals("+16 B")
+	verb.Raw.Bytes = true
+	assert.For("raw").That(verb.sizeDelta(100, 150)).Equals("+50, +50%")
+}
+
+func TestSummarizeResources(t *testing.T) {
+	assert := assert.To(t)
+	allocs := []*api.MemoryAllocation{
+		{Handle: 1, Bindings: []*api.MemoryBinding{
+			{Handle: 10, Name: "img", Size: 8},
+			{Handle: 10, Name: "img", Size: 8},
+			{Handle: 11, Name: "buf", Size: 32},
+		}},
+		{Handle: 2, Bindings: []*api.MemoryBinding{
+			{Handle: 10, Name: "img", Size: 16},
+			{Handle: 12, Name: "view", Size: 32},
+		}},
+	}
+	assert.For("resources").ThatSlice(summarizeResources(allocs, allocationFilter{})).Equals([]resourceUsage{
+		{handle: 10, name: "img", bindings: 3, allocations: 2, footprint: 32},
+		{handle: 11, name: "buf", bindings: 1, allocations: 1, footprint: 32},
+		{handle: 12, name: "view", bindings: 1, allocations: 1, footprint: 32},
+	})
+}
+
+func TestMemoryOverlayText(t *testing.T) {
+	assert := assert.To(t)
+	mem := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{
+		{Name: "a", Size: 1024},
+		{Name: "b", Size: 2048},
+		{Name: "c", Size: 2048},
+	}}
+	assert.For("text").That(memoryOverlayText(mem)).Equals("Memory: 5.0 KiB in 3 allocations\nLargest: b (2.0 KiB)")
+	assert.For("empty").That(memoryOverlayText(&api.MemoryBreakdown{})).Equals("Memory: 0 B in 0 allocations")
+}
+
+func TestTabWriter(t *testing.T) {
+	assert := assert.To(t)
+	buf := &bytes.Buffer{}
+	verb := &memoryVerb{out: buf}
+	w := verb.tabWriter(0)
+	fmt.Fprintln(w, "a_long_name\tx")
+	fmt.Fprintln(w, "b\ty")
+	w.Flush()
+	assert.For("default").ThatString(buf.String()).Equals("a_long_name x\nb           y\n")
+
+	buf.Reset()
+	verb.Min.Width, verb.Column.Padding = 6, 3
+	w = verb.tabWriter(0)
+	fmt.Fprintln(w, "a\tx")
+	w.Flush()
+	assert.For("flags").ThatString(buf.String()).Equals("a     x\n")
+}
+
+func TestCollectImageLayers(t *testing.T) {
+	assert := assert.To(t)
+	color := []api.AspectType{api.AspectType_COLOR}
+	block := func(mip, layer uint32, size uint64) *api.MemoryBinding {
+		return &api.MemoryBinding{Handle: 5, Name: "tex", Size: size, Type: &api.MemoryBinding_SparseImageBlock{
+			SparseImageBlock: &api.SparseImageBlock{MipLevel: mip, ArrayLayer: layer, Aspects: color},
+		}}
+	}
+	allocs := []*api.MemoryAllocation{
+		{Bindings: []*api.MemoryBinding{block(0, 0, 64), block(0, 0, 64), block(1, 1, 16)}},
+		{Bindings: []*api.MemoryBinding{
+			{Handle: 5, Size: 8, Type: &api.MemoryBinding_SparseImageMipTail{
+				SparseImageMipTail: &api.SparseImageMetadataMipTail{ArrayLayer: 1, Aspects: color},
+			}},
+			{Handle: 5, Size: 32, Type: &api.MemoryBinding_SparseOpaqueImageBlock{}},
+			{Handle: 6, Size: 32, Type: &api.MemoryBinding_Image{}},
+		}},
+	}
+	assert.For("layers").That(collectImageLayers(allocs, 5)).DeepEquals(imageLayers{
+		name:     "tex",
+		bindings: 5,
+		aspects: map[string]map[layerCell]uint64{
+			"Color": {{0, 0}: 128, {1, 1}: 16, {mipTailRow, 1}: 8},
+		},
+		whole: 32,
+	})
+}
+
+func TestMatchAllocations(t *testing.T) {
+	assert := assert.To(t)
+	oldSame := &api.MemoryAllocation{Handle: 1, Name: "a", Size: 16}
+	oldGrown := &api.MemoryAllocation{Handle: 2, Name: "a", Size: 32}
+	oldGone := &api.MemoryAllocation{Handle: 3, Name: "b", Size: 8}
+	newSame := &api.MemoryAllocation{Handle: 10, Name: "a", Size: 16}
+	newGrown := &api.MemoryAllocation{Handle: 11, Name: "a", Size: 64}
+	newAdded := &api.MemoryAllocation{Handle: 12, Name: "b", MemoryType: 1, Size: 8}
+	diff := matchAllocations(
+		&api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{oldSame, oldGrown, oldGone}},
+		&api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{newGrown, newSame, newAdded}})
+	assert.For("added").ThatSlice(diff.added).Equals([]*api.MemoryAllocation{newAdded})
+	assert.For("removed").ThatSlice(diff.removed).Equals([]*api.MemoryAllocation{oldGone})
+	assert.For("changed").ThatSlice(diff.changed).DeepEquals([]allocationDiff{{old: oldGrown, new: newGrown}})
+}
+
+func TestSortBySeverity(t *testing.T) {
+	assert := assert.To(t)
+	bindings := breakdown.Bindings{
+		{Handle: 1, Type: &api.MemoryBinding_Image{Image: &api.NormalBinding{}}},
+		{Handle: 2, Type: &api.MemoryBinding_Image{Image: &api.NormalBinding{}}},
+		{Handle: 3, Type: &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}},
+		{Handle: 4, Type: &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}},
+	}
+	aliases := []breakdown.Alias{
+		{Offset: 0, Sharers: []uint64{1, 2}},
+		{Offset: 8, Sharers: []uint64{3, 4}},
+		{Offset: 16, Sharers: []uint64{1, 3}},
+		{Offset: 24, Sharers: []uint64{3, 4}},
+	}
+	sortBySeverity(bindings, aliases)
+	offsets := []uint64{}
+	for _, a := range aliases {
+		offsets = append(offsets, a.Offset)
+	}
+	assert.For("offsets").ThatSlice(offsets).Equals([]uint64{8, 24, 16, 0})
+}
+
+func TestAPIName(t *testing.T) {
+	assert := assert.To(t)
+	assert.For("vulkan").That(apiName(apiPath("vulkan"))).Equals("vulkan")
+	assert.For("gles").That(apiName(apiPath("gles"))).Equals("gles")
+	assert.For("nil").That(apiName(nil)).Equals("unknown")
+	verb := &memoryVerb{}
+	verb.Api = "gles"
+	assert.For("known").ThatError(verb.checkAPI()).Succeeded()
+	verb.Api = "metal"
+	assert.For("unknown").ThatError(verb.checkAPI()).Failed()
+}
+
+func TestSliceRect(t *testing.T) {
+	assert := assert.To(t)
+	r := treemapRect{0, 0, 100, 50}
+	assert.For("horizontal").That(sliceRect(r, []uint64{1, 3}, true)).DeepEquals([]treemapRect{
+		{0, 0, 25, 50},
+		{25, 0, 75, 50},
+	})
+	assert.For("vertical").That(sliceRect(r, []uint64{2, 2}, false)).DeepEquals([]treemapRect{
+		{0, 0, 100, 25},
+		{0, 25, 100, 25},
+	})
+	assert.For("empty").That(sliceRect(r, []uint64{0}, true)).DeepEquals([]treemapRect{
+		{0, 0, 0, 50},
+	})
+}
+
+func TestAssertRules(t *testing.T) {
+	assert := assert.To(t)
+	rules, err := parseAssertRules(strings.NewReader(`
+# Budgets
+total < 1GiB
+count <= 2
+alloc "Staging Buffer".size < 64MiB
+alloc "Staging Buffer".bindings == 1
+no hard aliases
+no aliases
+`))
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("rules").That(len(rules)).Equals(6)
+
+	allocs := []*api.MemoryAllocation{
+		{Name: "Staging Buffer", Size: 1 << 20, Bindings: []*api.MemoryBinding{{Size: 1 << 20}}},
+		{Name: "Images", Size: 1 << 30, Aliases: []*api.MemoryAlias{{Sparse: true}}},
+	}
+	verb := &memoryVerb{}
+	passed := []bool{}
+	for _, rule := range rules {
+		ok, _ := verb.evalAssertRule(rule, allocs)
+		passed = append(passed, ok)
+	}
+	assert.For("passed").ThatSlice(passed).Equals([]bool{false, true, true, true, true, false})
+
+	missing, _ := parseAssertRule(`alloc "Missing".size < 1M`)
+	ok, detail := verb.evalAssertRule(missing, allocs)
+	assert.For("missing").That(ok).Equals(false)
+	assert.For("missing detail").That(detail).Equals(`no allocation named "Missing"`)
+
+	for _, s := range []string{"total < lots", "count < 1M", "alloc Staging.size < 1M", "no leaks"} {
+		_, err := parseAssertRule(s)
+		assert.For(s).ThatError(err).Failed()
+	}
+}
+
+func TestFollowEvent(t *testing.T) {
+	assert := assert.To(t)
+	a := &api.MemoryAllocation{Handle: 1}
+	b := &api.MemoryAllocation{Handle: 2}
+	at := func(alloc *api.MemoryAllocation, offset uint64) []resourceBinding {
+		return []resourceBinding{{alloc, &api.MemoryBinding{Offset: offset, Size: 16}}}
+	}
+	assert.For("none").That(followEvent(nil, nil)).Equals("")
+	assert.For("bound").That(followEvent(nil, at(a, 0))).Equals(followBound)
+	assert.For("same").That(followEvent(at(a, 0), at(a, 0))).Equals("")
+	assert.For("offset").That(followEvent(at(a, 0), at(a, 16))).Equals(followRebound)
+	assert.For("allocation").That(followEvent(at(a, 0), at(b, 0))).Equals(followRebound)
+	assert.For("unbound").That(followEvent(at(a, 0), nil)).Equals(followUnbound)
+}
+
+func TestBindingTypeFilter(t *testing.T) {
+	assert := assert.To(t)
+	verb := &memoryVerb{}
+	verb.Binding.Type = "buffer,Sparse-Image-Block"
+	verb.Binding.TypeAliases = true
+	filter, err := verb.allocationFilter()
+	assert.For("err").ThatError(err).Succeeded()
+
+	buffer := &api.MemoryBinding{Handle: 1, Size: 8, Type: &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}}
+	image := &api.MemoryBinding{Handle: 2, Size: 8, Type: &api.MemoryBinding_Image{Image: &api.NormalBinding{}}}
+	other := &api.MemoryBinding{Handle: 3, Size: 8, Type: &api.MemoryBinding_Image{Image: &api.NormalBinding{}}}
+	bindings := breakdown.Bindings{buffer, image, other}
+	assert.For("bindings").ThatSlice(filter.bindings(bindings)).Equals([]*api.MemoryBinding{buffer})
+	assert.For("hidden").That(filter.hiddenBindings(bindings, 1)).Equals("2 bindings hidden by filters")
+	filter.minBindingSize = 16
+	assert.For("smaller").That(filter.hiddenBindings(bindings, 0)).Equals("3 smaller bindings hidden")
+	filter.minBindingSize, other.Size = 8, 4
+	assert.For("both").That(filter.hiddenBindings(bindings, 1)).Equals("1 smaller bindings and 1 more hidden by filters")
+	filter.minBindingSize, other.Size = 0, 8
+
+	aliases := []breakdown.Alias{
+		{Offset: 0, Size: 4, Sharers: []uint64{1, 2}},
+		{Offset: 4, Size: 4, Sharers: []uint64{2, 3}},
+	}
+	assert.For("aliases").That(filter.aliases(aliases, bindings)).DeepEquals(aliases[:1])
+	filter.typeAliases = false
+	assert.For("all aliases").That(filter.aliases(aliases, bindings)).DeepEquals(aliases)
+
+	verb.Binding.Type = "texture"
+	_, err = verb.allocationFilter()
+	assert.For("unknown").ThatError(err).Failed()
+}
+
+func TestOverflow(t *testing.T) {
+	assert := assert.To(t)
+	assert.For("within").That(overflow(0, 256, 256)).Equals(uint64(0))
+	assert.For("past end").That(overflow(200, 100, 256)).Equals(uint64(44))
+	assert.For("after end").That(overflow(300, 10, 256)).Equals(uint64(54))
+	assert.For("saturated").That(overflow(300, ^uint64(0), 256)).Equals(^uint64(0))
+}
+
+func TestParseSortKeys(t *testing.T) {
+	assert := assert.To(t)
+	keys, err := parseSortKeys("size:desc, name:asc,type", false)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("keys").That(keys).DeepEquals([]sortKey{{"size", true}, {"name", false}, {"type", false}})
+	keys, err = parseSortKeys("size,name:asc", true)
+	assert.For("desc err").ThatError(err).Succeeded()
+	assert.For("desc keys").That(keys).DeepEquals([]sortKey{{"size", true}, {"name", false}})
+	_, err = parseSortKeys("size:up", false)
+	assert.For("direction").ThatError(err).Failed()
+	_, err = parseSortKeys("age", false)
+	assert.For("key").ThatError(err).Failed()
+}
+
+func TestSortAllocationsCompound(t *testing.T) {
+	assert := assert.To(t)
+	a := &api.MemoryAllocation{Handle: 1, Name: "b", Size: 10}
+	b := &api.MemoryAllocation{Handle: 2, Name: "a", Size: 20}
+	c := &api.MemoryAllocation{Handle: 3, Name: "a", Size: 10}
+	d := &api.MemoryAllocation{Handle: 4, Name: "c", Size: 20}
+	allocs := []*api.MemoryAllocation{a, b, c, d}
+
+	verb := &memoryVerb{}
+	verb.Sort = "size:desc,name:asc"
+	verb.sortAllocations(allocs)
+	assert.For("size desc, name asc").ThatSlice(allocs).Equals([]*api.MemoryAllocation{b, d, c, a})
+
+	verb.Sort = "name:desc,size"
+	verb.sortAllocations(allocs)
+	assert.For("name desc, size").ThatSlice(allocs).Equals([]*api.MemoryAllocation{d, a, c, b})
+}
+
+func TestBaselineRegressions(t *testing.T) {
+	assert := assert.To(t)
+	assert.For("within").That(overTolerance(100, 105, 5)).Equals(false)
+	assert.For("over").That(overTolerance(100, 106, 5)).Equals(true)
+	assert.For("shrunk").That(overTolerance(100, 50, 0)).Equals(false)
+
+	base := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{
+		{Handle: 1, Name: "Vertices", Size: 100},
+		{Handle: 2, Name: "Textures", Size: 100},
+		{Handle: 3, Name: "Staging", Size: 100},
+	}}
+	current := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{
+		{Handle: 11, Name: "Vertices", Size: 104},
+		{Handle: 12, Name: "Textures", Size: 200},
+		{Handle: 13, Name: "Uniforms", Size: 10},
+	}}
+	regressions := baselineRegressions(matchAllocations(base, current), 5)
+	assert.For("added").ThatSlice(regressions.added).Equals([]*api.MemoryAllocation{current.Allocations[2]})
+	assert.For("grown").That(len(regressions.changed)).Equals(1)
+	assert.For("grown name").That(regressions.changed[0].new.Name).Equals("Textures")
+	assert.For("removed").ThatSlice(regressions.removed).IsEmpty()
+}
+
+func TestSnapshotHeader(t *testing.T) {
+	assert := assert.To(t)
+	cmd := &path.Command{Indices: []uint64{42}}
+	assert.For("index").That(memorySnapshot{cmd, nil, ""}.header()).Equals("Memory at command [42]")
+	assert.For("label").That(memorySnapshot{cmd, nil, "frame:3"}.header()).Equals("Memory at command [42] (frame:3)")
+	assert.For("file").That(memorySnapshot{&path.Command{}, nil, "metrics.pb"}.header()).Equals("Memory from metrics.pb")
+}
+
+func TestMemoryTypeLegend(t *testing.T) {
+	assert := assert.To(t)
+	assert.For("stable").That(memoryTypeColor(3)).Equals(memoryTypeColor(3 + uint32(len(memoryTypeColors))))
+	assert.For("distinct").That(memoryTypeColor(0) != memoryTypeColor(1)).Equals(true)
+
+	flags := &service.ConstantSet{
+		Constants:  []*service.Constant{{Name: "DEVICE_LOCAL", Value: 1}, {Name: "HOST_VISIBLE", Value: 2}},
+		IsBitfield: true,
+	}
+	allocs := []*api.MemoryAllocation{
+		{MemoryType: 2, Flags: 2},
+		{MemoryType: 0, Flags: 1},
+		{MemoryType: 2, Flags: 2},
+	}
+	names, colors := memoryTypeLegend(allocs, breakdown.NewFlagNameCache(flags))
+	assert.For("names").ThatSlice(names).Equals([]string{"Memory type 0 (DEVICE_LOCAL)", "Memory type 2 (HOST_VISIBLE)"})
+	assert.For("colors").ThatSlice(colors).Equals([]string{memoryTypeColor(0), memoryTypeColor(2)})
+}
+
+func TestMemoryJSONStream(t *testing.T) {
+	assert := assert.To(t)
+	ctx := log.Testing(t)
+	buf := &bytes.Buffer{}
+	verb := &memoryVerb{out: buf}
+	mem := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{
+		{Name: "a", Handle: 1, Size: 16},
+		{Name: "b", Handle: 2, Size: 32},
+	}}
+	snapshots := []memorySnapshot{{&path.Command{Indices: []uint64{7}}, mem, ""}}
+	err := verb.printMemoryJSONStream(ctx, snapshots, nil, allocationFilter{})
+	assert.For("err").ThatError(err).Succeeded()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.For("lines").That(len(lines)).Equals(2)
+	line := allocationLineJSON{}
+	assert.For("decode").ThatError(json.Unmarshal([]byte(lines[1]), &line)).Succeeded()
+	assert.For("command").ThatSlice(line.Command).Equals([]uint64{7})
+	assert.For("name").That(line.Name).Equals("b")
+	assert.For("size").That(line.Size).Equals(uint64(32))
+}
+
+func TestDescribeDevice(t *testing.T) {
+	assert := assert.To(t)
+	nvidia := &device.VulkanPhysicalDevice{
+		DeviceName:    "NVIDIA GeForce RTX 3080",
+		VendorId:      vendorNVIDIA,
+		DriverVersion: 535<<22 | 104<<14,
+	}
+	withDrivers := func(gpu string, vulkan *device.VulkanDriver) *device.Instance {
+		return &device.Instance{Configuration: &device.Configuration{
+			Hardware: &device.Hardware{GPU: &device.GPU{Name: gpu}},
+			Drivers:  &device.Drivers{Vulkan: vulkan},
+		}}
+	}
+	for _, test := range []struct {
+		name     string
+		device   *device.Instance
+		expected string
+	}{
+		{"none", nil, ""},
+		{"gpu", withDrivers("Adreno 640", nil), "Adreno 640"},
+		{"physical device", withDrivers("", &device.VulkanDriver{
+			PhysicalDevices: []*device.VulkanPhysicalDevice{nvidia},
+		}), "NVIDIA GeForce RTX 3080, driver 535.104"},
+		{"package version", withDrivers("Adreno 640", &device.VulkanDriver{
+			PhysicalDevices: []*device.VulkanPhysicalDevice{{DeviceName: "Adreno (TM) 640", DriverVersion: 512<<22 | 415}},
+			Version:         "415.0",
+		}), "Adreno (TM) 640, driver 415.0"},
+		{"several physical devices", withDrivers("Mali-G78", &device.VulkanDriver{
+			PhysicalDevices: []*device.VulkanPhysicalDevice{nvidia, nvidia},
+		}), "Mali-G78"},
+	} {
+		assert.For(test.name).That(describeDevice(test.device)).Equals(test.expected)
+	}
+
+	verb := &memoryVerb{}
+	assert.For("unknown").That(verb.deviceName(3)).Equals("3")
+	verb.traceDevice = "Adreno 640"
+	assert.For("unmatched").That(verb.deviceName(3)).Equals("3")
+	verb.traceDeviceID, verb.traceDeviceKnown = 3, true
+	assert.For("known").That(verb.deviceName(3)).Equals("3 (Adreno 640)")
+	assert.For("other").That(verb.deviceName(4)).Equals("4")
+
+	snapshot := func(devices ...uint64) memorySnapshot {
+		mem := &api.MemoryBreakdown{}
+		for _, d := range devices {
+			mem.Allocations = append(mem.Allocations, &api.MemoryAllocation{Device: d})
+		}
+		return memorySnapshot{&path.Command{}, mem, ""}
+	}
+	dev, ok := singleDevice([]memorySnapshot{snapshot(3, 3), snapshot(3)})
+	assert.For("single").That(ok).Equals(true)
+	assert.For("single device").That(dev).Equals(uint64(3))
+	_, ok = singleDevice([]memorySnapshot{snapshot(3), snapshot(4)})
+	assert.For("several").That(ok).Equals(false)
+	_, ok = singleDevice([]memorySnapshot{snapshot()})
+	assert.For("none").That(ok).Equals(false)
+}
+
+func TestNameFilter(t *testing.T) {
+	assert := assert.To(t)
+	verb := &memoryVerb{}
+	verb.Include.Name = "Buffer|Image"
+	verb.Exclude.Name = "^Internal"
+	filter, err := verb.allocationFilter()
+	assert.For("err").ThatError(err).Succeeded()
+
+	buffer := &api.MemoryAllocation{Name: "Vertex Buffer"}
+	internal := &api.MemoryAllocation{Name: "Internal Buffer"}
+	other := &api.MemoryAllocation{Name: "Descriptor Pool"}
+	allocs := []*api.MemoryAllocation{buffer, internal, other}
+	assert.For("filtered").ThatSlice(filter.apply(allocs)).Equals([]*api.MemoryAllocation{buffer})
+
+	verb.Exclude.Name = "(Internal"
+	_, err = verb.allocationFilter()
+	assert.For("invalid").ThatError(err).Failed()
+}
+
+func TestAllocationIDs(t *testing.T) {
+	assert := assert.To(t)
+	kept := &api.MemoryAllocation{Name: "Vertices", Handle: 1, Size: 16, AllocatedBy: 5}
+	freed := &api.MemoryAllocation{Name: "Staging", Handle: 2, Size: 16, AllocatedBy: 6}
+	keptLater := &api.MemoryAllocation{Name: "Vertices", Handle: 1, Size: 32, AllocatedBy: 5}
+	// The handle of the freed allocation is reused by an allocation of the
+	// same name, only the allocating command tells them apart.
+	reused := &api.MemoryAllocation{Name: "Staging", Handle: 2, Size: 16, AllocatedBy: 15}
+	from := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{kept, freed}}
+	to := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{keptLater, reused}}
+	assert.For("kept").That(allocationID(keptLater)).Equals(allocationID(kept))
+	assert.For("reused").That(allocationID(reused)).NotEquals(allocationID(freed))
+
+	diff := diffMemory(from, to)
+	assert.For("added").ThatSlice(diff.added).Equals([]*api.MemoryAllocation{reused})
+	assert.For("removed").ThatSlice(diff.removed).Equals([]*api.MemoryAllocation{freed})
+	assert.For("changed").That(len(diff.changed)).Equals(1)
+	assert.For("leaks").ThatSlice(findLeaks(from, to)).Equals([]*api.MemoryAllocation{reused})
+
+	// The allocation is only seen at the later point, as if the earlier one
+	// had not been requested, and keeps its id.
+	later := &api.MemoryAllocation{Name: "Staging", Handle: 2, Size: 16, AllocatedBy: 15}
+	assert.For("independent").That(allocationID(later)).Equals(allocationID(reused))
+}
+
+func TestCompactAliases(t *testing.T) {
+	assert := assert.To(t)
+	buffer := func(handle, offset, size uint64) *api.MemoryBinding {
+		return &api.MemoryBinding{Handle: handle, Offset: offset, Size: size,
+			Type: &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}}
+	}
+	alloc := &api.MemoryAllocation{Name: "a", Size: 4096, Mapping: &api.MemoryMapping{}, Bindings: []*api.MemoryBinding{
+		buffer(1, 0, 2048), buffer(2, 1024, 2048),
+	}}
+	mem := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{alloc}}
+	breakdown.ComputeAllocationAliasing(mem)
+	buf := &bytes.Buffer{}
+	verb := &memoryVerb{MemoryFlags: MemoryFlags{Color: "never"}}
+	verb.No.Bindings = true
+	verb.Compact.Aliases = true
+	verb.printAllocation(buf, alloc, nil, allocationFilter{})
+	assert.For("summary").ThatString(buf.String()).Contains("Aliases: \t1 regions, 1.0 KiB total, max severity HIGH\n")
+
+	buf.Reset()
+	alloc.Bindings = alloc.Bindings[:1]
+	breakdown.ComputeAllocationAliasing(mem)
+	verb.printAllocation(buf, alloc, nil, allocationFilter{})
+	assert.For("none").ThatString(buf.String()).Contains("Aliases: \tnone\n")
+}
+
+func TestPageAllocations(t *testing.T) {
+	assert := assert.To(t)
+	allocs := make([]*api.MemoryAllocation, 5)
+	for i := range allocs {
+		allocs[i] = &api.MemoryAllocation{Handle: uint64(i)}
+	}
+	assert.For("all").ThatSlice(pageAllocations(allocs, 0, 0)).Equals(allocs)
+	assert.For("first page").ThatSlice(pageAllocations(allocs, 0, 2)).Equals(allocs[:2])
+	assert.For("second page").ThatSlice(pageAllocations(allocs, 2, 2)).Equals(allocs[2:4])
+	assert.For("last page").ThatSlice(pageAllocations(allocs, 4, 2)).Equals(allocs[4:])
+	assert.For("offset only").ThatSlice(pageAllocations(allocs, 3, 0)).Equals(allocs[3:])
+	assert.For("past end").ThatSlice(pageAllocations(allocs, 7, 2)).IsEmpty()
+}
+
+func TestSampleStride(t *testing.T) {
+	assert := assert.To(t)
+	verb := &memoryVerb{MemoryFlags: MemoryFlags{PeakStride: 100}}
+	assert.For("follow").That(verb.sampleStride()).Equals(uint64(1))
+	verb.Peak = true
+	assert.For("peak").That(verb.sampleStride()).Equals(uint64(100))
+	verb.Watch = 10
+	assert.For("watch").That(verb.sampleStride()).Equals(uint64(10))
+}
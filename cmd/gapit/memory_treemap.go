@@ -0,0 +1,131 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io/ioutil"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
+	"github.com/google/gapid/gapis/service"
+)
+
+// The size of the treemap written with -treemap, and of each line of the
+// legend of the memory types below it.
+const (
+	treemapWidth  = 1200
+	treemapHeight = 800
+	legendHeight  = 20
+)
+
+// treemapRect is a rectangle of the treemap.
+type treemapRect struct {
+	x, y, w, h float64
+}
+
+// sliceRect splits the rectangle into slices with areas proportional to the
+// sizes, side by side horizontally, or stacked vertically.
+func sliceRect(r treemapRect, sizes []uint64, horizontal bool) []treemapRect {
+	total := uint64(0)
+	for _, s := range sizes {
+		total += s
+	}
+	out := make([]treemapRect, len(sizes))
+	offset := 0.0
+	for i, s := range sizes {
+		f := 0.0
+		if total > 0 {
+			f = float64(s) / float64(total)
+		}
+		if horizontal {
+			out[i] = treemapRect{r.x + offset*r.w, r.y, f * r.w, r.h}
+		} else {
+			out[i] = treemapRect{r.x, r.y + offset*r.h, r.w, f * r.h}
+		}
+		offset += f
+	}
+	return out
+}
+
+// memoryTreemap returns the SVG treemap of the allocations: a row per memory
+// type, with a height proportional to its total size, split into the
+// allocations of that type, colored by memory type, followed by the legend of
+// the memory types. The bindings are drawn within their allocation at their
+// offset, with a width proportional to their size.
+func (verb *memoryVerb) memoryTreemap(mem *api.MemoryBreakdown, flagNames *breakdown.FlagNameCache, filter allocationFilter) []byte {
+	buf := &bytes.Buffer{}
+	legend, colors := memoryTypeLegend(mem.Allocations, flagNames)
+	fmt.Fprintf(buf, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" font-family=\"sans-serif\" font-size=\"10\">\n",
+		treemapWidth, treemapHeight+legendHeight*len(legend))
+
+	groups := groupByMemoryType(mem.Allocations)
+	groupSizes := make([]uint64, len(groups))
+	for i, g := range groups {
+		groupSizes[i] = totalSize(g.allocations)
+	}
+	bounds := treemapRect{0, 0, treemapWidth, treemapHeight}
+	for i, row := range sliceRect(bounds, groupSizes, false) {
+		group := groups[i]
+		allocSizes := make([]uint64, len(group.allocations))
+		for j, alloc := range group.allocations {
+			allocSizes[j] = alloc.Size
+		}
+		for j, r := range sliceRect(row, allocSizes, true) {
+			alloc := group.allocations[j]
+			fmt.Fprintf(buf, "<g><title>%v (memory type %v, device %v): %v</title>\n",
+				html.EscapeString(alloc.Name), alloc.MemoryType, alloc.Device, verb.bytes(alloc.Size))
+			fmt.Fprintf(buf, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"%v\" stroke=\"white\"/>\n",
+				r.x, r.y, r.w, r.h, memoryTypeColor(alloc.MemoryType))
+			for _, b := range filter.bindings(alloc.Bindings) {
+				if alloc.Size == 0 || b.Offset >= alloc.Size {
+					continue
+				}
+				size := b.Size
+				if b.Offset+size > alloc.Size {
+					size = alloc.Size - b.Offset
+				}
+				x := r.x + r.w*float64(b.Offset)/float64(alloc.Size)
+				w := r.w * float64(size) / float64(alloc.Size)
+				fmt.Fprintf(buf, "<rect x=\"%.1f\" y=\"%.1f\" width=\"%.1f\" height=\"%.1f\" fill=\"black\" fill-opacity=\"0.15\"><title>%v: %v</title></rect>\n",
+					x, r.y+r.h/4, w, r.h/2, html.EscapeString(b.Name), verb.bytes(b.Size))
+			}
+			fmt.Fprintf(buf, "<text x=\"%.1f\" y=\"%.1f\">%v</text></g>\n",
+				r.x+2, r.y+12, html.EscapeString(alloc.Name))
+		}
+	}
+	for i, name := range legend {
+		y := treemapHeight + legendHeight*i
+		fmt.Fprintf(buf, "<rect x=\"4\" y=\"%v\" width=\"12\" height=\"12\" fill=\"%v\" stroke=\"black\"/>\n", y+4, colors[i])
+		fmt.Fprintf(buf, "<text x=\"22\" y=\"%v\">%v</text>\n", y+14, html.EscapeString(name))
+	}
+	fmt.Fprintln(buf, "</svg>")
+	return buf.Bytes()
+}
+
+// writeMemoryTreemap writes the SVG treemap of the allocations kept by the
+// filter to the -treemap file.
+func (verb *memoryVerb) writeMemoryTreemap(ctx context.Context, mem *api.MemoryBreakdown, allocationFlags *service.ConstantSet, filter allocationFilter) error {
+	data := verb.memoryTreemap(mem, verb.flagNameCache(allocationFlags), filter)
+	if err := ioutil.WriteFile(verb.Treemap, data, 0644); err != nil {
+		return log.Errf(ctx, err, "Failed to write the treemap to %v", verb.Treemap)
+	}
+	log.I(ctx, "Wrote the memory treemap to %v", verb.Treemap)
+	return nil
+}
@@ -0,0 +1,72 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// isUnused returns whether none of the memory of the allocation is bound,
+// either because it has no bindings, or only empty ones.
+func isUnused(alloc *api.MemoryAllocation) bool {
+	for _, b := range alloc.Bindings {
+		if b.Size != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// unusedAllocations returns the allocations that have no memory bound.
+func unusedAllocations(allocs []*api.MemoryAllocation) []*api.MemoryAllocation {
+	out := []*api.MemoryAllocation{}
+	for _, alloc := range allocs {
+		if isUnused(alloc) {
+			out = append(out, alloc)
+		}
+	}
+	return out
+}
+
+// printUnusedMemory prints the allocations of each snapshot that have no
+// memory bound, along with the total size of these allocations.
+func (verb *memoryVerb) printUnusedMemory(snapshots []memorySnapshot) {
+	for i, snapshot := range snapshots {
+		if len(snapshots) > 1 {
+			if i > 0 {
+				fmt.Fprintln(verb.out)
+			}
+			fmt.Fprintf(verb.out, "%v:\n", snapshot.header())
+		}
+		unused := unusedAllocations(snapshot.mem.Allocations)
+		wasted := uint64(0)
+		for _, alloc := range unused {
+			wasted += alloc.Size
+		}
+		w := verb.tabWriter(1)
+		fmt.Fprintf(w, "%v of %v allocations have no memory bound, %v wasted\n",
+			len(unused), len(snapshot.mem.Allocations), verb.bytes(wasted))
+		if len(unused) > 0 {
+			fmt.Fprintln(w, "Name\tHandle\tDevice\tMemory Type\tSize\tBindings")
+		}
+		for _, alloc := range unused {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", alloc.Name, verb.handle(alloc.Handle),
+				verb.deviceName(alloc.Device), alloc.MemoryType, verb.bytes(alloc.Size), len(alloc.Bindings))
+		}
+		w.Flush()
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/memory/breakdown"
+)
+
+// overflow returns the number of bytes of the range [offset, offset+size) past
+// the end of an allocation of the given size, saturating on overflow.
+func overflow(offset, size, allocSize uint64) uint64 {
+	if offset <= allocSize {
+		if size <= allocSize-offset {
+			return 0
+		}
+		return size - (allocSize - offset)
+	}
+	if size > math.MaxUint64-(offset-allocSize) {
+		return math.MaxUint64
+	}
+	return size + (offset - allocSize)
+}
+
+// validateBindings prints the bindings of each snapshot that extend past the
+// end of their allocation, along with the allocation, and fails if there are
+// any.
+func (verb *memoryVerb) validateBindings(ctx context.Context, snapshots []memorySnapshot) error {
+	invalid := 0
+	for i, snapshot := range snapshots {
+		if len(snapshots) > 1 {
+			if i > 0 {
+				fmt.Fprintln(verb.out)
+			}
+			fmt.Fprintf(verb.out, "%v:\n", snapshot.header())
+		}
+		w := verb.tabWriter(1)
+		count := 0
+		for _, alloc := range snapshot.mem.Allocations {
+			for _, b := range breakdown.Bindings(alloc.Bindings).OutOfBounds(alloc.Size) {
+				if count == 0 {
+					fmt.Fprintln(w, "Allocation\tSize\tBinding\tType\tOffset\tBinding Size\tOverflow")
+				}
+				count++
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", alloc.Name, verb.bytes(alloc.Size),
+					verb.sharerName(b.Handle, map[uint64]string{b.Handle: b.Name}), breakdown.BindingTypeName(b),
+					b.Offset, verb.bytes(b.Size), verb.bytes(overflow(b.Offset, b.Size, alloc.Size)))
+			}
+		}
+		if count == 0 {
+			fmt.Fprintln(w, "All the bindings are within their allocation")
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		invalid += count
+	}
+	if invalid > 0 {
+		return log.Errf(ctx, nil, "%v bindings are out of the bounds of their allocation", invalid)
+	}
+	return nil
+}
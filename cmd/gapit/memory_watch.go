@@ -0,0 +1,165 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/core/app/status"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// memorySample is the summary of the memory breakdown after a single command.
+type memorySample struct {
+	command uint64
+	total   uint64
+	count   int
+
+	// mapped is the total size of the mapped ranges of the allocations.
+	mapped uint64
+	// types is the total size of the allocations of each memory type.
+	types map[uint32]uint64
+}
+
+// scanMemory samples the memory breakdown every -watch commands, at the end of
+// every frame with -at-each-frame, or every -peak-stride commands if only -peak
+// is set, over the shared GAPIS connection, with up to -concurrency
+// outstanding requests.
+// With -watch or -at-each-frame, the total size and number of the allocations
+// kept by the filter are printed as a table, and written as a trace with
+// -perfetto. With -peak, the memory breakdown of the sample with the largest
+// total size is printed.
+func (verb *memoryVerb) scanMemory(ctx context.Context, captureFile string, filter allocationFilter) error {
+	client := verb.gapis
+	capture, err := loadCapture(ctx, client, captureFile, verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+
+	cmds, err := verb.sampleCommands(ctx, client, capture)
+	if err != nil {
+		return err
+	}
+	samples := make([]memorySample, len(cmds))
+	var peak *memorySnapshot
+	var peakTotal uint64
+	peakIndex := 0
+	ctx = status.Start(ctx, "Sampling memory breakdowns")
+	defer status.Finish(ctx)
+	err = verb.fetchEach(ctx, client, cmds, func(i int, mem *api.MemoryBreakdown) {
+		mem.Allocations = filter.apply(mem.Allocations)
+		sample := memorySample{
+			command: cmds[i].Indices[0],
+			count:   len(mem.Allocations),
+			types:   map[uint32]uint64{},
+		}
+		for _, alloc := range mem.Allocations {
+			sample.total += alloc.Size
+			sample.mapped += alloc.GetMapping().GetSize()
+			sample.types[alloc.MemoryType] += alloc.Size
+		}
+		samples[i] = sample
+		// As the samples complete in any order, the earliest of the samples
+		// with the largest total is the peak.
+		if verb.Peak && (peak == nil || sample.total > peakTotal || (sample.total == peakTotal && i < peakIndex)) {
+			peak, peakTotal, peakIndex = &memorySnapshot{cmds[i], mem, ""}, sample.total, i
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if verb.Watch > 0 || verb.EachFrame {
+		w := verb.tabWriter(2)
+		if verb.EachFrame {
+			fmt.Fprintln(w, "frame\tcommand_index\ttotal_bytes\talloc_count")
+			for i, s := range samples {
+				fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", i+1, s.command, s.total, s.count)
+			}
+		} else {
+			fmt.Fprintln(w, "command_index\ttotal_bytes\talloc_count")
+			for _, s := range samples {
+				fmt.Fprintf(w, "%v\t%v\t%v\n", s.command, s.total, s.count)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if verb.Perfetto != "" {
+			if err := writeMemoryTrace(ctx, verb.Perfetto, samples); err != nil {
+				return err
+			}
+		}
+	}
+
+	if peak != nil {
+		allocationFlags, err := verb.fetchAllocationFlags(ctx, client, peak.mem)
+		if err != nil {
+			return err
+		}
+		verb.sortAllocations(peak.mem.Allocations)
+		if verb.Watch > 0 || verb.EachFrame {
+			fmt.Fprintln(verb.out)
+		}
+		fmt.Fprintf(verb.out, "Peak of %v at command %v:\n", verb.bytes(peakTotal), peak.cmd.Indices)
+		verb.printMemory(peak.mem, allocationFlags, filter)
+	}
+	return nil
+}
+
+// sampleCommands returns the commands after which the memory is sampled: the
+// last command of each frame with -at-each-frame, otherwise every sampleStride
+// commands.
+func (verb *memoryVerb) sampleCommands(ctx context.Context, client service.Service, capture *path.Capture) ([]*path.Command, error) {
+	if verb.EachFrame {
+		ends, err := frameEnds(ctx, client, capture)
+		if err != nil {
+			return nil, err
+		}
+		if len(ends) == 0 {
+			return nil, log.Err(ctx, nil, "The capture has no frame boundaries, use -watch instead")
+		}
+		return ends, nil
+	}
+
+	numCommands, err := getNumCommands(ctx, client, capture)
+	if err != nil {
+		return nil, err
+	}
+	stride := verb.sampleStride()
+	cmds := []*path.Command{}
+	for cmd := uint64(0); cmd < numCommands; cmd += stride {
+		cmds = append(cmds, capture.Command(cmd))
+	}
+	return cmds, nil
+}
+
+// sampleStride returns the number of commands between the samples: -watch, or
+// -peak-stride with -peak, as sampling every command sends one request per
+// command of the capture. -follow-resource samples every command.
+func (verb *memoryVerb) sampleStride() uint64 {
+	switch {
+	case verb.Watch > 0:
+		return uint64(verb.Watch)
+	case verb.Peak:
+		return uint64(verb.PeakStride)
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+type metricsVerb MetricsFlags
+
+func init() {
+	verb := &metricsVerb{}
+	app.AddVerb(&app.Verb{
+		Name:      "metrics",
+		ShortHelp: "Prints a summary of all the metrics of a capture file",
+		Action:    verb,
+	})
+}
+
+func (verb *metricsVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	client, capture, err := getGapisAndLoadCapture(ctx, verb.Gapis, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	numCommands, err := getNumCommands(ctx, client, capture)
+	if err != nil {
+		return err
+	}
+	if len(verb.At) == 0 {
+		verb.At = []uint64{numCommands - 1}
+	}
+	if err := checkCommandIndex(ctx, verb.At, numCommands); err != nil {
+		return err
+	}
+
+	boxedMetrics, err := client.Get(ctx, allMetrics(capture.Command(verb.At[0], verb.At[1:]...)).Path(), nil)
+	if err != nil {
+		return log.Err(ctx, err, "Failed to load metrics")
+	}
+	values := []metricValue{}
+	flattenMetrics("", reflect.ValueOf(boxedMetrics.(*api.Metrics)), &values)
+
+	if verb.Json {
+		out := make(map[string]interface{}, len(values))
+		for _, v := range values {
+			out[v.key] = v.value
+		}
+		jsonBytes, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			return log.Err(ctx, err, "Couldn't marshal metrics to JSON")
+		}
+		fmt.Fprintln(os.Stdout, string(jsonBytes))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 4, 4, 2, ' ', 0)
+	for _, v := range values {
+		fmt.Fprintf(w, "%v\t%v\n", v.key, v.value)
+	}
+	return w.Flush()
+}
+
+// metricValue is a single value of a metric family, keyed by the path of its
+// field in the api.Metrics message.
+type metricValue struct {
+	key   string
+	value interface{}
+}
+
+// allMetrics returns a path requesting every metric family after the command
+// cmd. The families are the bool fields of path.Metrics, so new families are
+// requested without changes to this verb.
+func allMetrics(cmd *path.Command) *path.Metrics {
+	p := &path.Metrics{Command: cmd}
+	v := reflect.ValueOf(p).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.Bool && f.CanSet() {
+			f.SetBool(true)
+		}
+	}
+	return p
+}
+
+// flattenMetrics appends the scalar fields of the metrics message v to values,
+// keyed by their dot separated proto field names. Repeated fields are summarized
+// by their length, and unset messages are omitted.
+func flattenMetrics(prefix string, v reflect.Value, values *[]metricValue) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			flattenMetrics(prefix, v.Elem(), values)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := protoFieldName(t.Field(i))
+			if name == "" {
+				continue
+			}
+			if prefix != "" {
+				name = prefix + "." + name
+			}
+			flattenMetrics(name, v.Field(i), values)
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			*values = append(*values, metricValue{prefix + ".count", v.Len()})
+		}
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		*values = append(*values, metricValue{prefix, v.Interface()})
+	}
+}
+
+// protoFieldName returns the proto name of the struct field of a generated
+// message, or an empty string if the field is not a proto field.
+func protoFieldName(f reflect.StructField) string {
+	for _, part := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(part, "name=") {
+			return strings.TrimPrefix(part, "name=")
+		}
+	}
+	return ""
+}
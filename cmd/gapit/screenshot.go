@@ -19,6 +19,8 @@ import (
 	"flag"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
 	"image/png"
 	"os"
 	"strconv"
@@ -27,7 +29,10 @@ import (
 
 	"github.com/google/gapid/core/app"
 	"github.com/google/gapid/core/app/flags"
+	"github.com/google/gapid/core/image/font"
 	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
 
@@ -93,7 +98,13 @@ func (verb *screenshotVerb) Run(ctx context.Context, flags flag.FlagSet) error {
 
 			var err error
 			if frame, err := verb.getSingleFrame(ctx, command, device, client); err == nil {
-				err = verb.writeSingleFrame(flipImg(frame), formatOut(verb.Out, idx, multi))
+				frame = flipImg(frame)
+				if verb.Mem.Overlay {
+					err = drawMemoryOverlay(ctx, client, command, frame)
+				}
+				if err == nil {
+					err = verb.writeSingleFrame(frame, formatOut(verb.Out, idx, multi))
+				}
 			}
 			c <- err
 		}(idx, command)
@@ -122,6 +133,37 @@ func formatOut(out string, idx int, multi bool) string {
 
 }
 
+// drawMemoryOverlay draws the memory summary after the command over the top
+// left corner of the frame.
+func drawMemoryOverlay(ctx context.Context, client service.Service, cmd *path.Command, frame draw.Image) error {
+	mem, err := breakdown.Fetch(ctx, client, cmd)
+	if err != nil {
+		return err
+	}
+	str := memoryOverlayText(mem)
+	font.DrawString(str, frame, image.Pt(4, 4), color.Black)
+	font.DrawString(str, frame, image.Pt(2, 2), color.White)
+	return nil
+}
+
+// memoryOverlayText returns the total size and count of the allocations, and
+// the name and size of the largest allocation, as drawn by -mem-overlay.
+func memoryOverlayText(mem *api.MemoryBreakdown) string {
+	total := uint64(0)
+	var top *api.MemoryAllocation
+	for _, alloc := range mem.Allocations {
+		total += alloc.Size
+		if top == nil || alloc.Size > top.Size {
+			top = alloc
+		}
+	}
+	str := fmt.Sprintf("Memory: %v in %v allocations", humanBytes(total), len(mem.Allocations))
+	if top != nil {
+		str += fmt.Sprintf("\nLargest: %v (%v)", top.Name, humanBytes(top.Size))
+	}
+	return str
+}
+
 func (verb *screenshotVerb) writeSingleFrame(frame image.Image, fn string) error {
 	out, err := os.Create(fn)
 	if err != nil {
@@ -0,0 +1,187 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/google/gapid/core/app"
+	"github.com/google/gapid/core/app/auth"
+	"github.com/google/gapid/core/log"
+)
+
+type shellVerb struct{ ShellFlags }
+
+func init() {
+	verb := &shellVerb{}
+	app.AddVerb(&app.Verb{
+		Name:       "shell",
+		ShortHelp:  "Loads a capture once, then runs the verbs read from stdin against it",
+		ShortUsage: "<gfxtrace>",
+		Action:     verb,
+	})
+}
+
+// shellHelp is printed by the help command of the shell.
+const shellHelp = `Each line is a gapit verb and its flags, run against the loaded capture:
+  memory -at 100
+  images
+  at 200 memory        same as: memory -at 200
+  exit                 or quit, or the end of the input`
+
+// Run starts or connects to GAPIS and loads the capture once, then runs each
+// verb read from stdin in a new gapit process. The verbs connect to the same
+// GAPIS server through the environment, and refer to the capture by its ID,
+// so they don't pay for the startup and the loading of the capture again.
+func (verb *shellVerb) Run(ctx context.Context, flags flag.FlagSet) error {
+	if flags.NArg() != 1 {
+		app.Usage(ctx, "Exactly one gfx trace file expected, got %d", flags.NArg())
+		return nil
+	}
+
+	gapisFlags, err := gapisFlagsFromEnv(verb.Gapis, os.Getenv)
+	if err != nil {
+		return log.Err(ctx, err, "Invalid GAPIS connection settings")
+	}
+	port := gapisFlags.Port
+	if port == 0 {
+		// The server is started on a known port, with a known token, so that
+		// the verbs can connect to it. It has no idle timeout, as there is no
+		// traffic while waiting for the next line.
+		if port, err = freePort(); err != nil {
+			return log.Err(ctx, err, "Failed to find a free port for GAPIS")
+		}
+		gapisFlags.Args = fmt.Sprintf("%v --rpc localhost:%v --idle-timeout 0", gapisFlags.Args, port)
+		gapisFlags.Host = ""
+		gapisFlags.Token = string(auth.GenToken())
+	}
+
+	client, capture, err := getGapisAndLoadCapture(ctx, gapisFlags, GapirFlags{}, flags.Arg(0), verb.CaptureFileFlags)
+	if err != nil {
+		return err
+	}
+	defer closeGapis(ctx, client)
+	captureID := capture.ID.ID().String()
+
+	gapit, err := os.Executable()
+	if err != nil {
+		return log.Err(ctx, err, "Failed to find the gapit executable")
+	}
+	env := append(os.Environ(),
+		fmt.Sprintf("%v=%v", gapisHostEnv, gapisFlags.Host),
+		fmt.Sprintf("%v=%v", gapisPortEnv, port),
+		fmt.Sprintf("%v=%v", gapisTokenEnv, gapisFlags.Token))
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Print("gapit> ")
+		if !scanner.Scan() {
+			fmt.Println()
+			return scanner.Err()
+		}
+		args, err := splitShellLine(scanner.Text())
+		if err != nil {
+			log.E(ctx, "%v", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			fmt.Println(shellHelp)
+			continue
+		}
+
+		gapitArgs, err := shellArgs(args, captureID)
+		if err != nil {
+			log.E(ctx, "%v", err)
+			continue
+		}
+		cmd := exec.CommandContext(ctx, gapit, gapitArgs...)
+		cmd.Stdout, cmd.Stderr, cmd.Env = os.Stdout, os.Stderr, env
+		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return cancelled(ctx)
+			}
+			log.W(ctx, "%v failed: %v", args[0], err)
+		}
+	}
+}
+
+// freePort returns a TCP port of localhost that is currently free.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// shellArgs returns the gapit arguments of a shell command, rewriting
+// "at N verb ..." as "verb -at N ...", and passing the loaded capture by ID.
+func shellArgs(args []string, captureID string) ([]string, error) {
+	if args[0] == "at" {
+		if len(args) < 3 {
+			return nil, fmt.Errorf("Usage: at <command> <verb> [flags]")
+		}
+		args = append([]string{args[2], "-at", args[1]}, args[3:]...)
+	}
+	out := append([]string{args[0], "-captureid"}, args[1:]...)
+	return append(out, captureID), nil
+}
+
+// splitShellLine splits a shell command into its arguments, separated by
+// spaces, except within single or double quotes.
+func splitShellLine(line string) ([]string, error) {
+	args := []string{}
+	arg, inArg, quote := &strings.Builder{}, false, rune(0)
+	for _, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, inArg = r, true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("Unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,52 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+)
+
+func TestSplitShellLine(t *testing.T) {
+	assert := assert.To(t)
+	args, err := splitShellLine(`  memory -at 100   -format "{{.Name}} {{.Size}}" -out ''`)
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("args").ThatSlice(args).Equals([]string{"memory", "-at", "100", "-format", "{{.Name}} {{.Size}}", "-out", ""})
+	args, err = splitShellLine("   ")
+	assert.For("empty err").ThatError(err).Succeeded()
+	assert.For("empty").ThatSlice(args).IsEmpty()
+	_, err = splitShellLine(`memory -format "{{.Name}}`)
+	assert.For("unterminated").ThatError(err).Failed()
+}
+
+func TestShellArgs(t *testing.T) {
+	assert := assert.To(t)
+	for _, test := range []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{"verb", []string{"memory", "-at", "100"}, []string{"memory", "-captureid", "-at", "100", "abc"}},
+		{"at", []string{"at", "200", "memory", "-json"}, []string{"memory", "-captureid", "-at", "200", "-json", "abc"}},
+		{"no flags", []string{"images"}, []string{"images", "-captureid", "abc"}},
+	} {
+		args, err := shellArgs(test.args, "abc")
+		assert.For("%v err", test.name).ThatError(err).Succeeded()
+		assert.For(test.name).ThatSlice(args).Equals(test.expected)
+	}
+	_, err := shellArgs([]string{"at", "200"}, "abc")
+	assert.For("at without verb").ThatError(err).Failed()
+}
@@ -39,6 +39,15 @@ const (
 	UsageExit
 )
 
+// ExitError is an error returned by the main entry point to terminate the
+// application with a specific exit code.
+type ExitError struct {
+	Code ExitCode
+	Err  error
+}
+
+func (e ExitError) Error() string { return e.Err.Error() }
+
 var (
 	// CleanupTimeout is the time to wait for all cleanup signals to fire when shutting down.
 	CleanupTimeout = time.Second * 10
@@ -286,6 +286,9 @@ func doRun(main task.Task) int {
 	}
 	if err != nil {
 		log.E(ctx, "Main failed\nError: %v", err)
+		if exit, ok := errors.Cause(err).(ExitError); ok {
+			return int(exit.Code)
+		}
 		return exitFailure
 	}
 	return exitSuccess
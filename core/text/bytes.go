@@ -0,0 +1,76 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var byteUnits = []struct {
+	suffix string
+	scale  uint64
+}{
+	{"k", 1 << 10},
+	{"m", 1 << 20},
+	{"g", 1 << 30},
+	{"t", 1 << 40},
+	{"p", 1 << 50},
+}
+
+// ParseBytes parses a byte count with an optional unit suffix, e.g. "512",
+// "512K", "16M" or "2GiB". The units are powers of 1024 and are not case
+// sensitive. A trailing "B" or "iB" after the unit is ignored.
+func ParseBytes(s string) (uint64, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	str = strings.TrimSuffix(str, "b")
+	binary := strings.HasSuffix(str, "i")
+	str = strings.TrimSuffix(str, "i")
+
+	scale := uint64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str, scale = strings.TrimSuffix(str, u.suffix), u.scale
+			break
+		}
+	}
+	if binary && scale == 1 {
+		// "i" is only valid as part of a binary prefix, e.g. "KiB".
+		return 0, fmt.Errorf("Invalid byte count %q", s)
+	}
+
+	str = strings.TrimSpace(str)
+	if n, err := strconv.ParseUint(str, 10, 64); err == nil {
+		if n > math.MaxUint64/scale {
+			return 0, fmt.Errorf("Byte count %q is too large", s)
+		}
+		return n * scale, nil
+	}
+	// Only plain decimals are accepted, ParseFloat also accepts signs,
+	// exponents, "nan" and "inf".
+	if strings.Trim(str, "0123456789.") != "" {
+		return 0, fmt.Errorf("Invalid byte count %q", s)
+	}
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid byte count %q", s)
+	}
+	if f*float64(scale) >= math.MaxUint64 {
+		return 0, fmt.Errorf("Byte count %q is too large", s)
+	}
+	return uint64(f * float64(scale)), nil
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text_test
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/text"
+)
+
+func TestParseBytes(t *testing.T) {
+	for _, test := range []struct {
+		str    string
+		expect uint64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"512B", 512},
+		{"1K", 1024},
+		{"1k", 1024},
+		{"512K", 512 * 1024},
+		{"16M", 16 * 1024 * 1024},
+		{"16MB", 16 * 1024 * 1024},
+		{"16MiB", 16 * 1024 * 1024},
+		{"1.5K", 1536},
+		{" 2G ", 2 * 1024 * 1024 * 1024},
+		{"1T", 1 << 40},
+	} {
+		got, err := text.ParseBytes(test.str)
+		if err != nil {
+			t.Errorf("ParseBytes(%q) failed: %v", test.str, err)
+		} else if got != test.expect {
+			t.Errorf("ParseBytes(%q): Expected %v got %v", test.str, test.expect, got)
+		}
+	}
+
+	for _, str := range []string{"", "K", "abc", "-1", "1X", "16384P", "nan", "NaN", "inf", "+Inf", "1iB", "1i", "1e3"} {
+		if _, err := text.ParseBytes(str); err == nil {
+			t.Errorf("ParseBytes(%q) succeeded, expected an error", str)
+		}
+	}
+}
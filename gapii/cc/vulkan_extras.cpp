@@ -500,6 +500,8 @@ void VulkanSpy::onCommandAdded(CallObserver*, VkCommandBuffer) {}
 uint32_t VulkanSpy::onesCount(CallObserver*, uint32_t x) {
   return std::bitset<32>(x).count();
 }
+// The spy doesn't know the index of the commands in the capture.
+uint64_t VulkanSpy::commandNumber(CallObserver*) { return 0; }
 
 gapil::Ref<PhysicalDevicesAndProperties>
 VulkanSpy::fetchPhysicalDeviceProperties(CallObserver* observer,
@@ -497,3 +497,10 @@ func (e externs) recordEndCommandBuffer(commandBuffer VkCommandBuffer) {
 func (e externs) onesCount(a uint32) uint32 {
 	return (uint32)(bits.OnesCount32(a))
 }
+
+func (e externs) commandNumber() uint64 {
+	if e.cmdID == api.CmdNoID {
+		return 0
+	}
+	return uint64(e.cmdID) + 1
+}
@@ -70,6 +70,10 @@ func (a API) MemoryBreakdown(st *api.GlobalState) (*api.MemoryBreakdown, error)
 		if err != nil {
 			return nil, err
 		}
+		heap, heapSize, err := s.getMemoryTypeHeap(device, typ)
+		if err != nil {
+			return nil, err
+		}
 		bindings, err := s.getAllocationBindings(info.Get(), st)
 		if err != nil {
 			return nil, err
@@ -89,11 +93,15 @@ func (a API) MemoryBreakdown(st *api.GlobalState) (*api.MemoryBreakdown, error)
 			Device:     uint64(info.Device()),
 			MemoryType: uint32(typ),
 			Flags:      uint32(flags),
+			Heap:       heap,
+			HeapSize:   heapSize,
 			Handle:     uint64(handle),
 			Name:       strconv.FormatUint(uint64(handle), 10),
 			Size:       uint64(info.AllocationSize()),
 			Mapping:    &mapping,
 			Bindings:   bindings,
+			// Tells apart the allocations that reused a freed handle.
+			AllocatedBy: info.AllocatedBy(),
 		}
 
 		allocations = append(allocations, &alloc)
@@ -284,22 +292,56 @@ func (s sparseBindingMap) getImageSparseBindings(img ImageObjectʳ) error {
 	return nil
 }
 
-func (s *State) getMemoryTypeFlags(device VkDevice, typeIndex uint32) (VkMemoryPropertyFlags, error) {
+func (s *State) getMemoryProperties(device VkDevice) (VkPhysicalDeviceMemoryProperties, error) {
 	deviceObject := s.Devices().Get(device)
 	if deviceObject.IsNil() {
-		return VkMemoryPropertyFlags(0), fmt.Errorf("Failed to find device %v", device)
+		return VkPhysicalDeviceMemoryProperties{}, fmt.Errorf("Failed to find device %v", device)
 	}
 	physicalDevice := deviceObject.PhysicalDevice()
 	physicalDeviceObject := s.PhysicalDevices().Get(physicalDevice)
 	if physicalDeviceObject.IsNil() {
-		return VkMemoryPropertyFlags(0), fmt.Errorf("Failed to find physical device %v", physicalDevice)
+		return VkPhysicalDeviceMemoryProperties{}, fmt.Errorf("Failed to find physical device %v", physicalDevice)
+	}
+	return physicalDeviceObject.MemoryProperties(), nil
+}
+
+func (s *State) getMemoryType(device VkDevice, typeIndex uint32) (VkMemoryType, error) {
+	props, err := s.getMemoryProperties(device)
+	if err != nil {
+		return VkMemoryType{}, err
 	}
-	props := physicalDeviceObject.MemoryProperties()
 	if props.MemoryTypeCount() <= typeIndex {
-		return VkMemoryPropertyFlags(0), fmt.Errorf("Memory type %v is larger than physical device %v's number of memory types (%v)",
-			typeIndex, physicalDevice, props.MemoryTypeCount())
+		return VkMemoryType{}, fmt.Errorf("Memory type %v is larger than device %v's number of memory types (%v)",
+			typeIndex, device, props.MemoryTypeCount())
+	}
+	return props.MemoryTypes().Get(int(typeIndex)), nil
+}
+
+func (s *State) getMemoryTypeFlags(device VkDevice, typeIndex uint32) (VkMemoryPropertyFlags, error) {
+	typ, err := s.getMemoryType(device, typeIndex)
+	if err != nil {
+		return VkMemoryPropertyFlags(0), err
+	}
+	return typ.PropertyFlags(), nil
+}
+
+// getMemoryTypeHeap returns the index and size of the heap the memory type is
+// allocated from.
+func (s *State) getMemoryTypeHeap(device VkDevice, typeIndex uint32) (uint32, uint64, error) {
+	typ, err := s.getMemoryType(device, typeIndex)
+	if err != nil {
+		return 0, 0, err
+	}
+	props, err := s.getMemoryProperties(device)
+	if err != nil {
+		return 0, 0, err
+	}
+	heap := typ.HeapIndex()
+	if props.MemoryHeapCount() <= heap {
+		return 0, 0, fmt.Errorf("Memory heap %v is larger than device %v's number of memory heaps (%v)",
+			heap, device, props.MemoryHeapCount())
 	}
-	return props.MemoryTypes().Get(int(typeIndex)).PropertyFlags(), nil
+	return heap, uint64(props.MemoryHeaps().Get(int(heap)).Size()), nil
 }
 
 func (s *State) getAllocationBindings(allocation DeviceMemoryObject, st *api.GlobalState) ([]*api.MemoryBinding, error) {
@@ -24,6 +24,7 @@ import (
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/core/log/log_pb"
 	"github.com/google/gapid/core/net/grpcutil"
+	"github.com/google/gapid/gapis/api"
 	perfetto "github.com/google/gapid/gapis/perfetto/service"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
@@ -564,6 +565,19 @@ func (c *client) PerfettoQuery(ctx context.Context, capture *path.Capture, query
 	return res.GetResult(), nil
 }
 
+func (c *client) MemoryAliasing(ctx context.Context, cmd *path.Command) (*api.MemoryBreakdown, error) {
+	res, err := c.client.MemoryAliasing(ctx, &service.MemoryAliasingRequest{
+		Command: cmd,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := res.GetError(); err != nil {
+		return nil, err.Get()
+	}
+	return res.GetBreakdown(), nil
+}
+
 func (c *client) ValidateDevice(ctx context.Context, device *path.Device) error {
 	res, err := c.client.ValidateDevice(ctx, &service.ValidateDeviceRequest{
 		Device: device,
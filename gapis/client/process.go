@@ -34,6 +34,7 @@ const (
 
 type Config struct {
 	Path  *file.Path
+	Host  string
 	Port  int
 	Args  []string
 	Token auth.Token
@@ -41,7 +42,8 @@ type Config struct {
 
 // Connect attempts to connect to a GAPIS process.
 // If port is zero, a new GAPIS server will be started, otherwise a connection
-// will be made to the specified port.
+// will be made to the specified port of the host, localhost if empty. A new
+// server is always started on localhost.
 func Connect(ctx context.Context, cfg Config) (Client, error) {
 	var err error
 	if cfg.Path == nil {
@@ -51,6 +53,7 @@ func Connect(ctx context.Context, cfg Config) (Client, error) {
 	}
 
 	if cfg.Port == 0 {
+		cfg.Host = ""
 		cfg.Args = append(cfg.Args,
 			"--log-level", logLevel(ctx).String(),
 			"--log-style", log.Brief.String(),
@@ -69,7 +72,11 @@ func Connect(ctx context.Context, cfg Config) (Client, error) {
 		}
 	}
 
-	target := fmt.Sprintf("localhost:%d", cfg.Port)
+	host := cfg.Host
+	if host == "" {
+		host = "localhost"
+	}
+	target := fmt.Sprintf("%v:%d", host, cfg.Port)
 
 	conn, err := grpcutil.Dial(ctx, target,
 		grpc.WithInsecure(),
@@ -0,0 +1,63 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breakdown
+
+import (
+	"sort"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// ComputeAllocationAliasing stores the aliased regions and non-conflicting
+// overlaps of each of the allocations of mem in the allocations. This is done
+// by GAPIS for the MemoryAliasing RPC, or when the metrics are requested with
+// path.Metrics.MemoryAliasing, so all clients agree on the aliased regions.
+func ComputeAllocationAliasing(mem *api.MemoryBreakdown) {
+	for _, alloc := range mem.Allocations {
+		bindings := append(Bindings{}, alloc.Bindings...)
+		sort.Slice(bindings, bindings.Less)
+		aliases, overlaps := bindings.ComputeOverlaps()
+		alloc.Aliases = toProto(aliases)
+		alloc.Overlaps = toProto(overlaps)
+	}
+	mem.Aliasing = true
+}
+
+// AllocationOverlaps returns the aliased regions and non-conflicting overlaps
+// of the allocation, as stored by ComputeAllocationAliasing.
+func AllocationOverlaps(alloc *api.MemoryAllocation) (aliases, overlaps []Alias) {
+	return fromProto(alloc.Aliases), fromProto(alloc.Overlaps)
+}
+
+func toProto(regions []Alias) []*api.MemoryAlias {
+	out := make([]*api.MemoryAlias, len(regions))
+	for i, a := range regions {
+		out[i] = &api.MemoryAlias{
+			Offset:  a.Offset,
+			Size:    a.Size,
+			Sharers: a.Sharers,
+			Sparse:  a.Sparse,
+		}
+	}
+	return out
+}
+
+func fromProto(regions []*api.MemoryAlias) []Alias {
+	out := make([]Alias, len(regions))
+	for i, a := range regions {
+		out[i] = Alias{Offset: a.Offset, Size: a.Size, Sharers: a.Sharers, Sparse: a.Sparse}
+	}
+	return out
+}
@@ -0,0 +1,424 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breakdown
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/gapid/gapis/api"
+)
+
+// Bindings is a list of the bindings of a single memory allocation.
+type Bindings []*api.MemoryBinding
+
+// Less orders the bindings by offset, then size, then handle. It is meant to
+// be used with sort.Slice.
+func (bindings Bindings) Less(i, j int) bool {
+	if bindings[i].Offset != bindings[j].Offset {
+		return bindings[i].Offset < bindings[j].Offset
+	}
+	if bindings[i].Size != bindings[j].Size {
+		return bindings[i].Size < bindings[j].Size
+	}
+	return bindings[i].Handle < bindings[j].Handle
+}
+
+// Names returns the map of binding handles to binding names.
+func (bindings Bindings) Names() map[uint64]string {
+	names := make(map[uint64]string, len(bindings))
+	for _, b := range bindings {
+		names[b.Handle] = b.Name
+	}
+	return names
+}
+
+// IsSparse returns whether the binding is one of the sparse binding types.
+func IsSparse(b *api.MemoryBinding) bool {
+	switch b.Type.(type) {
+	case *api.MemoryBinding_Buffer, *api.MemoryBinding_Image:
+		return false
+	}
+	return true
+}
+
+// Kind returns the user-readable classification of the aliased region.
+func (a Alias) Kind() string {
+	if a.Sparse {
+		return "sparse"
+	}
+	return "hard"
+}
+
+// AliasSeverity is how likely an aliased region is to be a bug.
+type AliasSeverity int
+
+const (
+	// SeverityNone is a region shared only by sparse bindings, which may
+	// legitimately be co-resident.
+	SeverityNone AliasSeverity = iota
+	// SeverityLow is a region shared only by images, such as transient
+	// attachments deliberately reusing the same memory.
+	SeverityLow
+	// SeverityMedium is any other hard alias, such as a buffer sharing
+	// memory with an image or a sparse resource.
+	SeverityMedium
+	// SeverityHigh is a region shared by several normally bound buffers,
+	// which are likely to be written through each other.
+	SeverityHigh
+)
+
+func (s AliasSeverity) String() string {
+	switch s {
+	case SeverityNone:
+		return "none"
+	case SeverityLow:
+		return "low"
+	case SeverityMedium:
+		return "medium"
+	case SeverityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("AliasSeverity(%d)", int(s))
+}
+
+// AliasSeverity scores the aliased region from the types of the bindings of
+// its sharers. The memory breakdown doesn't have the usage of the resources,
+// so buffers are assumed to be writable. Sparse regions, as classified when
+// the aliasing was computed, are never a bug.
+func (bindings Bindings) AliasSeverity(a Alias) AliasSeverity {
+	if a.Sparse {
+		return SeverityNone
+	}
+	sharers := make(map[uint64]struct{}, len(a.Sharers))
+	for _, s := range a.Sharers {
+		sharers[s] = struct{}{}
+	}
+	buffers, images, sparse := 0, 0, 0
+	for _, b := range bindings {
+		if _, ok := sharers[b.Handle]; !ok {
+			continue
+		}
+		switch b.Type.(type) {
+		case *api.MemoryBinding_Buffer:
+			buffers++
+		case *api.MemoryBinding_Image:
+			images++
+		default:
+			sparse++
+		}
+	}
+	switch {
+	case buffers >= 2:
+		return SeverityHigh
+	case buffers == 0 && sparse == 0:
+		return SeverityLow
+	default:
+		return SeverityMedium
+	}
+}
+
+// Coalesce merges the contiguous runs of bindings of the same resource and
+// type, which must be sorted by Less. It returns the merged bindings, along
+// with the number of bindings each merged binding was made from. The
+// original bindings are left untouched, so aliasing can still be computed on
+// them.
+func (bindings Bindings) Coalesce() (Bindings, map[*api.MemoryBinding]int) {
+	out := Bindings{}
+	merged := map[*api.MemoryBinding]int{}
+	for _, b := range bindings {
+		if len(out) > 0 {
+			last := out[len(out)-1]
+			if last.Handle == b.Handle && BindingTypeName(last) == BindingTypeName(b) &&
+				last.Offset+last.Size == b.Offset {
+				if merged[last] == 1 {
+					// Copy the binding before growing it.
+					last = &api.MemoryBinding{
+						Handle: last.Handle,
+						Name:   last.Name,
+						Offset: last.Offset,
+						Size:   last.Size,
+						Type:   last.Type,
+					}
+					merged[last] = 1
+					out[len(out)-1] = last
+				}
+				last.Size += b.Size
+				merged[last]++
+				continue
+			}
+		}
+		out = append(out, b)
+		merged[b] = 1
+	}
+	return out, merged
+}
+
+// Fragmentation returns the total size of the gaps between the bindings,
+// which must be sorted by Less. Overlapping bindings are coalesced first, so
+// aliased regions never produce negative gaps. Space before the first binding
+// and after the last one is not counted as a gap.
+func (bindings Bindings) Fragmentation() uint64 {
+	gaps, end, started := uint64(0), uint64(0), false
+	for _, b := range bindings {
+		if b.Size == 0 {
+			continue
+		}
+		if started && b.Offset > end {
+			gaps += b.Offset - end
+		}
+		if !started || b.Offset+b.Size > end {
+			end = b.Offset + b.Size
+		}
+		started = true
+	}
+	return gaps
+}
+
+// Unbound returns the number of bytes of an allocation of the given size that
+// aren't covered by any of the bindings, which must be sorted by Less. Unlike
+// Fragmentation, the space before the first binding and after the last one is
+// counted, and the bytes bound by several aliased bindings are counted once.
+func (bindings Bindings) Unbound(size uint64) uint64 {
+	bound, end := uint64(0), uint64(0)
+	for _, b := range bindings {
+		start, stop := b.Offset, b.Offset+b.Size
+		if stop > size {
+			stop = size
+		}
+		if start < end {
+			start = end
+		}
+		if stop > start {
+			bound += stop - start
+			end = stop
+		}
+	}
+	return size - bound
+}
+
+// OutOfBounds returns the bindings that extend past the end of an allocation
+// of the given size, which indicates corrupt capture data or an invalid
+// binding made by the application.
+func (bindings Bindings) OutOfBounds(size uint64) Bindings {
+	out := Bindings{}
+	for _, b := range bindings {
+		if b.Offset > size || b.Size > size-b.Offset {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// Alias is a region of memory shared by several bindings. Sharers are the
+// handles of the bindings, in increasing order.
+type Alias struct {
+	Offset uint64
+	Size   uint64
+
+	Sharers []uint64
+	// Sparse is whether all the bindings sharing the region are sparse
+	// bindings. Sparse resources may legitimately share memory, whereas any
+	// overlap with a normally bound buffer or image is a hard alias.
+	Sparse bool
+}
+
+// bindingAspects returns the image aspects covered by the binding, or nil if
+// the binding is not restricted to particular aspects.
+func bindingAspects(b *api.MemoryBinding) []api.AspectType {
+	switch val := b.Type.(type) {
+	case *api.MemoryBinding_SparseImageBlock:
+		return val.SparseImageBlock.Aspects
+	case *api.MemoryBinding_SparseImageMipTail:
+		return val.SparseImageMipTail.Aspects
+	}
+	return nil
+}
+
+// aspectsIntersect returns whether the two bindings may cover the same image
+// aspects. Bindings that are not restricted to particular aspects intersect
+// with everything.
+func aspectsIntersect(a, b *api.MemoryBinding) bool {
+	x, y := bindingAspects(a), bindingAspects(b)
+	if len(x) == 0 || len(y) == 0 {
+		return true
+	}
+	for _, i := range x {
+		for _, j := range y {
+			if i == j {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ComputeAliasing returns the regions of memory shared by bindings whose
+// aspects intersect.
+func (bindings Bindings) ComputeAliasing() []Alias {
+	aliases, _ := bindings.ComputeOverlaps()
+	return aliases
+}
+
+// ComputeOverlaps returns the regions of memory shared by more than one
+// binding. Regions where some of the sharers' aspects intersect are returned
+// as aliases, while regions only shared by bindings of disjoint aspects (e.g.
+// the depth and stencil of an image) are returned as non-conflicting overlaps.
+func (bindings Bindings) ComputeOverlaps() (aliases, overlaps []Alias) {
+	aliases, overlaps = []Alias{}, []Alias{}
+	if len(bindings) == 0 {
+		return aliases, overlaps
+	}
+	// The bindings are tracked by index, as a resource may have several
+	// sparse bindings in the same allocation.
+	startsAt := map[uint64][]int{}
+	endsAt := map[uint64][]int{}
+	pointSet := map[uint64]struct{}{}
+
+	for i, b := range bindings {
+		if b.Size == 0 {
+			// Zero sized bindings cannot alias anything.
+			continue
+		}
+		start := b.Offset
+		end := start + b.Size
+
+		startsAt[start] = append(startsAt[start], i)
+		pointSet[start] = struct{}{}
+
+		endsAt[end] = append(endsAt[end], i)
+		pointSet[end] = struct{}{}
+	}
+
+	if len(pointSet) == 0 {
+		return aliases, overlaps
+	}
+	points := make([]uint64, 0, len(pointSet))
+	for k := range pointSet {
+		points = append(points, k)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i] < points[j] })
+
+	active := map[int]struct{}{}
+	for i, p := range points[:len(points)-1] {
+		// The bindings ending at p must be removed before the ones starting at
+		// p are added: the ranges are half-open, so a binding ending where
+		// another starts doesn't share any byte with it.
+		for _, b := range endsAt[p] {
+			delete(active, b)
+		}
+		for _, b := range startsAt[p] {
+			active[b] = struct{}{}
+		}
+		if len(active) < 2 {
+			continue
+		}
+
+		sharing := make([]int, 0, len(active))
+		for b := range active {
+			sharing = append(sharing, b)
+		}
+		sort.Ints(sharing)
+
+		conflict, sparse := false, true
+		handles := map[uint64]struct{}{}
+		for j, x := range sharing {
+			handles[bindings[x].Handle] = struct{}{}
+			sparse = sparse && IsSparse(bindings[x])
+			for _, y := range sharing[j+1:] {
+				conflict = conflict || aspectsIntersect(bindings[x], bindings[y])
+			}
+		}
+		sharers := make([]uint64, 0, len(handles))
+		for h := range handles {
+			sharers = append(sharers, h)
+		}
+		sort.Slice(sharers, func(i, j int) bool { return sharers[i] < sharers[j] })
+
+		region := Alias{
+			Offset:  p,
+			Size:    points[i+1] - p,
+			Sharers: sharers,
+			Sparse:  sparse,
+		}
+		if conflict {
+			aliases = append(aliases, region)
+		} else {
+			overlaps = append(overlaps, region)
+		}
+	}
+
+	return aliases, overlaps
+}
+
+// AliasPair is the memory range [Start, End) shared by two bindings whose
+// aspects intersect.
+type AliasPair struct {
+	First  *api.MemoryBinding
+	Second *api.MemoryBinding
+	Start  uint64
+	End    uint64
+}
+
+// ComputeAliasPairs returns each pair of overlapping bindings whose aspects
+// intersect, with the exact range they share. Unlike ComputeAliasing, the
+// regions aren't merged, so with three or more partially overlapping bindings
+// each pair is reported separately. The bindings must be sorted by Less, and
+// the pairs are returned in the same order as their first binding.
+func (bindings Bindings) ComputeAliasPairs() []AliasPair {
+	pairs := []AliasPair{}
+	for i, a := range bindings {
+		if a.Size == 0 {
+			continue
+		}
+		end := a.Offset + a.Size
+		for _, b := range bindings[i+1:] {
+			if b.Offset >= end {
+				// The following bindings start even later.
+				break
+			}
+			if b.Size == 0 || !aspectsIntersect(a, b) {
+				continue
+			}
+			pair := AliasPair{First: a, Second: b, Start: b.Offset, End: end}
+			if b.Offset+b.Size < end {
+				pair.End = b.Offset + b.Size
+			}
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// OpaqueBlockOverlaps returns each pair of a sparse opaque image block and a
+// sparse image block of the same image that bind the same memory, with the
+// exact range they share. Binding the same image memory both ways is
+// undefined behavior. The bindings must be sorted by Less.
+func (bindings Bindings) OpaqueBlockOverlaps() []AliasPair {
+	pairs := []AliasPair{}
+	for _, p := range bindings.ComputeAliasPairs() {
+		if p.First.Handle != p.Second.Handle {
+			continue
+		}
+		_, firstOpaque := p.First.Type.(*api.MemoryBinding_SparseOpaqueImageBlock)
+		_, firstBlock := p.First.Type.(*api.MemoryBinding_SparseImageBlock)
+		_, secondOpaque := p.Second.Type.(*api.MemoryBinding_SparseOpaqueImageBlock)
+		_, secondBlock := p.Second.Type.(*api.MemoryBinding_SparseImageBlock)
+		if (firstOpaque && secondBlock) || (firstBlock && secondOpaque) {
+			pairs = append(pairs, p)
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,366 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breakdown
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/gapis/api"
+)
+
+func TestComputeAliasingIgnoresZeroSize(t *testing.T) {
+	assert := assert.To(t)
+
+	bindings := Bindings{
+		{Handle: 1, Offset: 0, Size: 0},
+		{Handle: 2, Offset: 0, Size: 16},
+		{Handle: 3, Offset: 8, Size: 0},
+		{Handle: 4, Offset: 8, Size: 16},
+		{Handle: 5, Offset: 24, Size: 0},
+	}
+	assert.For("aliases").That(bindings.ComputeAliasing()).DeepEquals([]Alias{
+		{Offset: 8, Size: 8, Sharers: []uint64{2, 4}, Sparse: true},
+	})
+
+	zeroOnly := Bindings{
+		{Handle: 1, Offset: 0, Size: 0},
+		{Handle: 2, Offset: 0, Size: 0},
+	}
+	assert.For("zero only").That(zeroOnly.ComputeAliasing()).DeepEquals([]Alias{})
+}
+
+func TestAliasKind(t *testing.T) {
+	assert := assert.To(t)
+
+	buffer := &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}
+	image := &api.MemoryBinding_Image{Image: &api.NormalBinding{}}
+	sparseBuffer := &api.MemoryBinding_SparseBufferBlock{SparseBufferBlock: &api.SparseBinding{}}
+	sparseImage := &api.MemoryBinding_SparseImageBlock{SparseImageBlock: &api.SparseImageBlock{}}
+
+	bindings := Bindings{
+		{Handle: 1, Offset: 0, Size: 16, Type: sparseBuffer},
+		{Handle: 2, Offset: 0, Size: 16, Type: sparseImage},
+		{Handle: 3, Offset: 32, Size: 16, Type: buffer},
+		{Handle: 4, Offset: 40, Size: 16, Type: sparseImage},
+		{Handle: 5, Offset: 64, Size: 16, Type: buffer},
+		{Handle: 6, Offset: 64, Size: 16, Type: image},
+	}
+	aliases := bindings.ComputeAliasing()
+	kinds := make([]string, len(aliases))
+	for i, a := range aliases {
+		kinds[i] = a.Kind()
+	}
+	assert.For("kinds").ThatSlice(kinds).Equals([]string{"sparse", "hard", "hard"})
+}
+
+func TestFragmentation(t *testing.T) {
+	assert := assert.To(t)
+	bindings := Bindings{
+		{Handle: 1, Offset: 0, Size: 100},
+		{Handle: 2, Offset: 50, Size: 100},
+		{Handle: 3, Offset: 60, Size: 20},
+		{Handle: 4, Offset: 200, Size: 0},
+		{Handle: 5, Offset: 300, Size: 100},
+	}
+	assert.For("fragmentation").That(bindings.Fragmentation()).Equals(uint64(150))
+	assert.For("empty").That(Bindings{}.Fragmentation()).Equals(uint64(0))
+}
+
+func TestUnbound(t *testing.T) {
+	assert := assert.To(t)
+	full := Bindings{
+		{Handle: 1, Offset: 0, Size: 100},
+		{Handle: 2, Offset: 100, Size: 156},
+	}
+	assert.For("fully bound").That(full.Unbound(256)).Equals(uint64(0))
+	partial := Bindings{
+		{Handle: 1, Offset: 16, Size: 32},
+		{Handle: 2, Offset: 64, Size: 64},
+	}
+	assert.For("partially bound").That(partial.Unbound(256)).Equals(uint64(160))
+	aliased := Bindings{
+		{Handle: 1, Offset: 0, Size: 200},
+		{Handle: 2, Offset: 50, Size: 100},
+		{Handle: 3, Offset: 150, Size: 150},
+	}
+	assert.For("over bound").That(aliased.Unbound(256)).Equals(uint64(0))
+	assert.For("empty").That(Bindings{}.Unbound(64)).Equals(uint64(64))
+}
+
+func TestOutOfBounds(t *testing.T) {
+	assert := assert.To(t)
+	bindings := Bindings{
+		{Handle: 1, Offset: 0, Size: 256},
+		{Handle: 2, Offset: 200, Size: 100},
+		{Handle: 3, Offset: 300, Size: 0},
+		{Handle: 4, Offset: 8, Size: ^uint64(0)},
+	}
+	assert.For("out of bounds").That(bindings.OutOfBounds(256)).DeepEquals(Bindings{bindings[1], bindings[2], bindings[3]})
+	assert.For("in bounds").That(bindings[:1].OutOfBounds(256)).DeepEquals(Bindings{})
+}
+
+func TestComputeOverlapsAspects(t *testing.T) {
+	assert := assert.To(t)
+
+	block := func(aspects ...api.AspectType) *api.MemoryBinding_SparseImageBlock {
+		return &api.MemoryBinding_SparseImageBlock{SparseImageBlock: &api.SparseImageBlock{Aspects: aspects}}
+	}
+	color, depth, stencil := api.AspectType_COLOR, api.AspectType_DEPTH, api.AspectType_STENCIL
+	buffer := &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}
+
+	for _, test := range []struct {
+		name    string
+		a, b    api.AspectType
+		aliased bool
+	}{
+		{"color/color", color, color, true},
+		{"depth/depth", depth, depth, true},
+		{"depth/stencil", depth, stencil, false},
+		{"color/depth", color, depth, false},
+		{"color/stencil", color, stencil, false},
+	} {
+		bindings := Bindings{
+			{Handle: 1, Offset: 0, Size: 16, Type: block(test.a)},
+			{Handle: 1, Offset: 0, Size: 16, Type: block(test.b)},
+		}
+		aliases, overlaps := bindings.ComputeOverlaps()
+		assert.For("%v aliased", test.name).That(len(aliases) == 1).Equals(test.aliased)
+		assert.For("%v overlapped", test.name).That(len(overlaps) == 1).Equals(!test.aliased)
+	}
+
+	bindings := Bindings{
+		{Handle: 1, Offset: 0, Size: 16, Type: block(depth, stencil)},
+		{Handle: 2, Offset: 0, Size: 16, Type: block(stencil)},
+		{Handle: 3, Offset: 32, Size: 16, Type: block(depth)},
+		{Handle: 4, Offset: 32, Size: 16, Type: buffer},
+	}
+	aliases, overlaps := bindings.ComputeOverlaps()
+	assert.For("partial and unrestricted aspects").That(aliases).DeepEquals([]Alias{
+		{Offset: 0, Size: 16, Sharers: []uint64{1, 2}, Sparse: true},
+		{Offset: 32, Size: 16, Sharers: []uint64{3, 4}},
+	})
+	assert.For("no overlaps").That(overlaps).DeepEquals([]Alias{})
+}
+
+func TestCoalesce(t *testing.T) {
+	assert := assert.To(t)
+
+	buffer := &api.MemoryBinding_SparseBufferBlock{SparseBufferBlock: &api.SparseBinding{}}
+	image := &api.MemoryBinding_SparseImageBlock{SparseImageBlock: &api.SparseImageBlock{}}
+	bindings := Bindings{
+		{Handle: 1, Offset: 0, Size: 16, Type: buffer},
+		{Handle: 1, Offset: 16, Size: 16, Type: buffer},
+		{Handle: 1, Offset: 32, Size: 16, Type: buffer},
+		{Handle: 1, Offset: 64, Size: 16, Type: buffer},
+		{Handle: 1, Offset: 80, Size: 16, Type: image},
+		{Handle: 2, Offset: 96, Size: 16, Type: image},
+	}
+	coalesced, merged := bindings.Coalesce()
+
+	type run struct {
+		offset, size uint64
+		count        int
+	}
+	runs := make([]run, len(coalesced))
+	for i, b := range coalesced {
+		runs[i] = run{b.Offset, b.Size, merged[b]}
+	}
+	assert.For("runs").ThatSlice(runs).Equals([]run{
+		{0, 48, 3},
+		{64, 16, 1},
+		{80, 16, 1},
+		{96, 16, 1},
+	})
+	assert.For("original").That(bindings[0].Size).Equals(uint64(16))
+}
+
+func TestComputeAliasPairs(t *testing.T) {
+	assert := assert.To(t)
+
+	a := &api.MemoryBinding{Handle: 1, Offset: 0, Size: 32}
+	b := &api.MemoryBinding{Handle: 2, Offset: 8, Size: 16}
+	c := &api.MemoryBinding{Handle: 3, Offset: 16, Size: 32}
+	d := &api.MemoryBinding{Handle: 4, Offset: 48, Size: 16}
+	bindings := Bindings{a, b, c, d}
+	assert.For("pairs").That(bindings.ComputeAliasPairs()).DeepEquals([]AliasPair{
+		{First: a, Second: b, Start: 8, End: 24},
+		{First: a, Second: c, Start: 16, End: 32},
+		{First: b, Second: c, Start: 16, End: 24},
+	})
+}
+
+func TestComputeAliasing(t *testing.T) {
+	assert := assert.To(t)
+
+	for _, test := range []struct {
+		name     string
+		bindings Bindings
+		expect   []Alias
+	}{
+		{
+			"identical",
+			Bindings{
+				{Handle: 1, Offset: 16, Size: 32},
+				{Handle: 2, Offset: 16, Size: 32},
+			},
+			[]Alias{{Offset: 16, Size: 32, Sharers: []uint64{1, 2}, Sparse: true}},
+		},
+		{
+			"nested",
+			Bindings{
+				{Handle: 1, Offset: 0, Size: 64},
+				{Handle: 2, Offset: 16, Size: 16},
+			},
+			[]Alias{{Offset: 16, Size: 16, Sharers: []uint64{1, 2}, Sparse: true}},
+		},
+		{
+			"touching",
+			Bindings{
+				{Handle: 1, Offset: 0, Size: 16},
+				{Handle: 2, Offset: 16, Size: 16},
+				{Handle: 3, Offset: 32, Size: 16},
+			},
+			[]Alias{},
+		},
+		{
+			"three way",
+			Bindings{
+				{Handle: 1, Offset: 0, Size: 32},
+				{Handle: 2, Offset: 8, Size: 32},
+				{Handle: 3, Offset: 16, Size: 32},
+			},
+			[]Alias{
+				{Offset: 8, Size: 8, Sharers: []uint64{1, 2}, Sparse: true},
+				{Offset: 16, Size: 16, Sharers: []uint64{1, 2, 3}, Sparse: true},
+				{Offset: 32, Size: 8, Sharers: []uint64{2, 3}, Sparse: true},
+			},
+		},
+		{
+			"zero size",
+			Bindings{
+				{Handle: 1, Offset: 0, Size: 0},
+				{Handle: 2, Offset: 0, Size: 0},
+			},
+			[]Alias{},
+		},
+		{
+			"empty",
+			Bindings{},
+			[]Alias{},
+		},
+	} {
+		assert.For(test.name).That(test.bindings.ComputeAliasing()).DeepEquals(test.expect)
+	}
+}
+
+func TestComputeAliasingTouchingRanges(t *testing.T) {
+	assert := assert.To(t)
+
+	// The ranges are half-open, so bindings that only touch at a point never
+	// alias, whatever their order in the list.
+	bindings := Bindings{
+		{Handle: 2, Offset: 16, Size: 16},
+		{Handle: 1, Offset: 0, Size: 16},
+		{Handle: 3, Offset: 16, Size: 0},
+		{Handle: 4, Offset: 32, Size: 8},
+	}
+	aliases, overlaps := bindings.ComputeOverlaps()
+	assert.For("aliases").That(aliases).DeepEquals([]Alias{})
+	assert.For("overlaps").That(overlaps).DeepEquals([]Alias{})
+	sort.Slice(bindings, bindings.Less)
+	assert.For("pairs").That(bindings.ComputeAliasPairs()).DeepEquals([]AliasPair{})
+}
+
+func TestComputeAllocationAliasing(t *testing.T) {
+	assert := assert.To(t)
+
+	alloc := &api.MemoryAllocation{Bindings: []*api.MemoryBinding{
+		{Handle: 2, Offset: 8, Size: 16, Type: &api.MemoryBinding_Buffer{}},
+		{Handle: 1, Offset: 0, Size: 16, Type: &api.MemoryBinding_Buffer{}},
+	}}
+	mem := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{alloc}}
+	ComputeAllocationAliasing(mem)
+	assert.For("aliasing").That(mem.Aliasing).Equals(true)
+	assert.For("proto").That(alloc.Aliases).DeepEquals([]*api.MemoryAlias{
+		{Offset: 8, Size: 8, Sharers: []uint64{1, 2}, Sparse: false},
+	})
+	aliases, overlaps := AllocationOverlaps(alloc)
+	assert.For("aliases").That(aliases).DeepEquals([]Alias{{Offset: 8, Size: 8, Sharers: []uint64{1, 2}}})
+	assert.For("overlaps").That(overlaps).DeepEquals([]Alias{})
+	assert.For("bindings order").That(alloc.Bindings[0].Handle).Equals(uint64(2))
+
+	sparse := &api.MemoryBinding_SparseBufferBlock{SparseBufferBlock: &api.SparseBinding{}}
+	alloc.Bindings = []*api.MemoryBinding{
+		{Handle: 1, Offset: 0, Size: 16, Type: sparse},
+		{Handle: 2, Offset: 8, Size: 16, Type: sparse},
+	}
+	ComputeAllocationAliasing(mem)
+	aliases, _ = AllocationOverlaps(alloc)
+	assert.For("sparse").That(aliases).DeepEquals([]Alias{{Offset: 8, Size: 8, Sharers: []uint64{1, 2}, Sparse: true}})
+	assert.For("sparse proto").That(alloc.Aliases[0].Sparse).Equals(true)
+	assert.For("sparse severity").That(Bindings(alloc.Bindings).AliasSeverity(aliases[0])).Equals(SeverityNone)
+}
+
+func TestOpaqueBlockOverlaps(t *testing.T) {
+	assert := assert.To(t)
+
+	opaque := &api.MemoryBinding_SparseOpaqueImageBlock{SparseOpaqueImageBlock: &api.SparseBinding{}}
+	block := &api.MemoryBinding_SparseImageBlock{SparseImageBlock: &api.SparseImageBlock{}}
+
+	bindings := Bindings{
+		{Handle: 1, Offset: 0, Size: 16, Type: opaque},
+		{Handle: 1, Offset: 8, Size: 16, Type: block},
+		{Handle: 2, Offset: 32, Size: 16, Type: opaque},
+		{Handle: 3, Offset: 40, Size: 16, Type: block},
+		{Handle: 4, Offset: 64, Size: 16, Type: block},
+		{Handle: 4, Offset: 72, Size: 16, Type: block},
+	}
+	sort.Slice(bindings, bindings.Less)
+	assert.For("overlaps").That(bindings.OpaqueBlockOverlaps()).DeepEquals([]AliasPair{
+		{First: bindings[0], Second: bindings[1], Start: 8, End: 16},
+	})
+}
+
+func TestAliasSeverity(t *testing.T) {
+	assert := assert.To(t)
+	buffer := &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}
+	image := &api.MemoryBinding_Image{Image: &api.NormalBinding{}}
+	sparse := &api.MemoryBinding_SparseBufferBlock{SparseBufferBlock: &api.SparseBinding{}}
+	bindings := Bindings{
+		{Handle: 1, Type: buffer},
+		{Handle: 2, Type: buffer},
+		{Handle: 3, Type: image},
+		{Handle: 4, Type: image},
+		{Handle: 5, Type: sparse},
+		{Handle: 6, Type: sparse},
+	}
+	for _, test := range []struct {
+		name     string
+		sharers  []uint64
+		sparse   bool
+		severity AliasSeverity
+	}{
+		{"buffers", []uint64{1, 2}, false, SeverityHigh},
+		{"buffer/image", []uint64{1, 3}, false, SeverityMedium},
+		{"buffer/sparse", []uint64{1, 5}, false, SeverityMedium},
+		{"images", []uint64{3, 4}, false, SeverityLow},
+		{"sparse", []uint64{5, 6}, true, SeverityNone},
+	} {
+		alias := Alias{Sharers: test.sharers, Sparse: test.sparse}
+		assert.For(test.name).That(bindings.AliasSeverity(alias)).Equals(test.severity)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breakdown
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+// FetchMemoryBreakdown returns the memory breakdown after the command cmd,
+// along with the allocation flag constants.
+func FetchMemoryBreakdown(ctx context.Context, client service.Service, cmd *path.Command) (*api.MemoryBreakdown, *service.ConstantSet, error) {
+	mem, err := Fetch(ctx, client, cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	allocationFlags, err := FetchAllocationFlags(ctx, client, mem)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mem, allocationFlags, nil
+}
+
+// Fetch returns the memory breakdown after the command cmd, along with the
+// aliased regions of its allocations, as computed by GAPIS.
+func Fetch(ctx context.Context, client service.Service, cmd *path.Command) (*api.MemoryBreakdown, error) {
+	mem, err := client.MemoryAliasing(ctx, cmd)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Failed to load the memory breakdown")
+	}
+	if mem == nil {
+		// GAPIS leaves the breakdown unset for the APIs without a
+		// MemoryBreakdownProvider, such as GLES.
+		return nil, log.Err(ctx, nil, "The capture has no memory breakdown, its API may not support it (only Vulkan captures do)")
+	}
+	return mem, nil
+}
+
+// FetchAllocationFlags returns the constants of the flags of the allocations of
+// mem, or nil if the API has no allocation flags.
+func FetchAllocationFlags(ctx context.Context, client service.Service, mem *api.MemoryBreakdown) (*service.ConstantSet, error) {
+	if mem.AllocationFlagsIndex == -1 {
+		return nil, nil
+	}
+	boxedConstants, err := client.Get(ctx, (&path.ConstantSet{
+		API:   mem.API,
+		Index: mem.AllocationFlagsIndex,
+	}).Path(), nil)
+	if err != nil {
+		return nil, log.Errf(ctx, err, "Failed to load allocation flag names")
+	}
+	return boxedConstants.(*service.ConstantSet), nil
+}
+
+// FlagNames returns the names of the allocation flags set in flags. If the
+// constants are not a bitfield, flags is a single value, and the name of the
+// constant with that value is returned. Values without a constant are returned
+// in hex, so they are not hidden.
+func FlagNames(flags uint32, allocationFlags *service.ConstantSet) []string {
+	names := []string{}
+	if allocationFlags == nil {
+		return names
+	}
+	if !allocationFlags.IsBitfield {
+		for _, f := range allocationFlags.Constants {
+			if uint64(flags) == f.Value {
+				return append(names, f.Name)
+			}
+		}
+		return append(names, fmt.Sprintf("0x%x (unknown flag)", flags))
+	}
+	for _, f := range allocationFlags.Constants {
+		if (flags & uint32(f.Value)) != 0 {
+			names = append(names, f.Name)
+		}
+	}
+	return names
+}
+
+// FlagNameCache caches the names of the allocation flags of each flag mask, as
+// many allocations usually share the same few masks.
+type FlagNameCache struct {
+	allocationFlags *service.ConstantSet
+	names           map[uint32][]string
+}
+
+// NewFlagNameCache returns an empty cache of the names of the allocation flags
+// of the given constants, which may be nil.
+func NewFlagNameCache(allocationFlags *service.ConstantSet) *FlagNameCache {
+	return &FlagNameCache{allocationFlags, map[uint32][]string{}}
+}
+
+// Constants returns the allocation flag constants the names are cached for.
+func (c *FlagNameCache) Constants() *service.ConstantSet {
+	return c.allocationFlags
+}
+
+// Names returns the names of the allocation flags set in flags, as returned
+// by FlagNames. The returned slice is shared and must not be modified.
+func (c *FlagNameCache) Names(flags uint32) []string {
+	names, ok := c.names[flags]
+	if !ok {
+		names = FlagNames(flags, c.allocationFlags)
+		c.names[flags] = names
+	}
+	return names
+}
+
+// BindingTypeName returns the user-readable name of the type of binding.
+func BindingTypeName(binding *api.MemoryBinding) string {
+	switch binding.Type.(type) {
+	case *api.MemoryBinding_Buffer:
+		return "Buffer"
+	case *api.MemoryBinding_Image:
+		return "Image"
+	case *api.MemoryBinding_SparseImageBlock:
+		return "Sparse Image Block"
+	case *api.MemoryBinding_SparseImageMetadata:
+		return "Sparse Image Metadata"
+	case *api.MemoryBinding_SparseImageMipTail:
+		return "Sparse Image Mip Tail"
+	case *api.MemoryBinding_SparseOpaqueImageBlock:
+		return "Sparse Opaque Image Block"
+	case *api.MemoryBinding_SparseBufferBlock:
+		return "Sparse Buffer Block"
+	}
+	return ""
+}
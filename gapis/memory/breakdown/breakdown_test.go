@@ -0,0 +1,143 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breakdown
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/service"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestFlagNames(t *testing.T) {
+	assert := assert.To(t)
+	constants := []*service.Constant{
+		{Name: "DEVICE_LOCAL", Value: 1},
+		{Name: "HOST_VISIBLE", Value: 2},
+		{Name: "HOST_COHERENT", Value: 4},
+	}
+	bitfield := &service.ConstantSet{Constants: constants, IsBitfield: true}
+	enum := &service.ConstantSet{Constants: constants}
+
+	assert.For("nil").ThatSlice(FlagNames(3, nil)).IsEmpty()
+	assert.For("bitfield").ThatSlice(FlagNames(6, bitfield)).Equals([]string{"HOST_VISIBLE", "HOST_COHERENT"})
+	assert.For("enum").ThatSlice(FlagNames(2, enum)).Equals([]string{"HOST_VISIBLE"})
+	assert.For("unknown").ThatSlice(FlagNames(6, enum)).Equals([]string{"0x6 (unknown flag)"})
+}
+
+func TestMarshal(t *testing.T) {
+	assert := assert.To(t)
+	mem := &api.MemoryBreakdown{
+		Allocations: []*api.MemoryAllocation{{
+			Handle: 1,
+			Size:   64,
+			Bindings: []*api.MemoryBinding{
+				{Handle: 2, Offset: 0, Size: 32},
+				{Handle: 3, Offset: 16, Size: 32},
+			},
+		}},
+		AllocationFlagsIndex: -1,
+	}
+	data, err := Marshal(mem)
+	assert.For("marshal").ThatError(err).Succeeded()
+	got, err := Unmarshal(data)
+	assert.For("unmarshal").ThatError(err).Succeeded()
+	ComputeAllocationAliasing(mem)
+	assert.For("breakdown").That(proto.Equal(got, mem)).Equals(true)
+
+	_, err = Unmarshal(nil)
+	assert.For("no breakdown").ThatError(err).Failed()
+
+	data, err = MarshalJSON(mem)
+	assert.For("marshal json").ThatError(err).Succeeded()
+	got, err = UnmarshalJSON(data)
+	assert.For("unmarshal json").ThatError(err).Succeeded()
+	assert.For("json breakdown").That(proto.Equal(got, mem)).Equals(true)
+}
+
+func TestFlagNameCache(t *testing.T) {
+	assert := assert.To(t)
+	bitfield := &service.ConstantSet{Constants: []*service.Constant{
+		{Name: "DEVICE_LOCAL", Value: 1},
+		{Name: "HOST_VISIBLE", Value: 2},
+	}, IsBitfield: true}
+	cache := NewFlagNameCache(bitfield)
+	assert.For("first").ThatSlice(cache.Names(3)).Equals([]string{"DEVICE_LOCAL", "HOST_VISIBLE"})
+	assert.For("cached").ThatSlice(cache.Names(3)).Equals([]string{"DEVICE_LOCAL", "HOST_VISIBLE"})
+	assert.For("nil").ThatSlice(NewFlagNameCache(nil).Names(3)).IsEmpty()
+}
+
+// aliasingService is a service that returns the same memory breakdown after
+// every command.
+type aliasingService struct {
+	service.Service
+	mem *api.MemoryBreakdown
+}
+
+func (s aliasingService) MemoryAliasing(ctx context.Context, c *path.Command) (*api.MemoryBreakdown, error) {
+	return s.mem, nil
+}
+
+func TestFetch(t *testing.T) {
+	ctx := log.Testing(t)
+	assert := assert.To(t)
+	mem := &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{{Handle: 1, Size: 64}}, Aliasing: true}
+	got, err := Fetch(ctx, aliasingService{mem: mem}, &path.Command{})
+	assert.For("err").ThatError(err).Succeeded()
+	assert.For("breakdown").That(got).Equals(mem)
+
+	// The API of the capture doesn't support memory breakdowns.
+	_, err = Fetch(ctx, aliasingService{}, &path.Command{})
+	assert.For("unsupported").ThatError(err).HasMessage("The capture has no memory breakdown, its API may not support it (only Vulkan captures do)")
+}
+
+// benchmarkFlags returns 32 bitfield constants, and the flags of 10000
+// allocations sharing 4 distinct masks.
+func benchmarkFlags() (*service.ConstantSet, []uint32) {
+	constants := &service.ConstantSet{IsBitfield: true}
+	for i := uint(0); i < 32; i++ {
+		constants.Constants = append(constants.Constants, &service.Constant{Name: fmt.Sprintf("FLAG_%v", i), Value: 1 << i})
+	}
+	flags := make([]uint32, 10000)
+	for i := range flags {
+		flags[i] = []uint32{0x1, 0x6, 0xe, 0x10f}[i%4]
+	}
+	return constants, flags
+}
+
+func BenchmarkFlagNames(b *testing.B) {
+	constants, flags := benchmarkFlags()
+	for i := 0; i < b.N; i++ {
+		for _, f := range flags {
+			FlagNames(f, constants)
+		}
+	}
+}
+
+func BenchmarkFlagNameCache(b *testing.B) {
+	constants, flags := benchmarkFlags()
+	for i := 0; i < b.N; i++ {
+		cache := NewFlagNameCache(constants)
+		for _, f := range flags {
+			cache.Names(f)
+		}
+	}
+}
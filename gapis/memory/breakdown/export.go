@@ -0,0 +1,72 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package breakdown
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gapid/gapis/api"
+)
+
+// Marshal encodes the memory breakdown, with all of its allocations and
+// bindings, as a binary api.Metrics protobuf. The breakdown can be read back
+// losslessly with Unmarshal.
+func Marshal(mem *api.MemoryBreakdown) ([]byte, error) {
+	return proto.Marshal(&api.Metrics{MemoryBreakdown: mem})
+}
+
+// Unmarshal decodes a memory breakdown encoded as a binary api.Metrics
+// protobuf, such as written by Marshal, in place of Fetch. The aliased regions
+// are computed if they weren't encoded.
+func Unmarshal(data []byte) (*api.MemoryBreakdown, error) {
+	metrics := &api.Metrics{}
+	if err := proto.Unmarshal(data, metrics); err != nil {
+		return nil, err
+	}
+	return fromMetrics(metrics)
+}
+
+// MarshalJSON encodes the memory breakdown like Marshal, but as the indented
+// JSON form of the api.Metrics protobuf, to be read back with UnmarshalJSON.
+func MarshalJSON(mem *api.MemoryBreakdown) ([]byte, error) {
+	m := jsonpb.Marshaler{Indent: "  "}
+	s, err := m.MarshalToString(&api.Metrics{MemoryBreakdown: mem})
+	return []byte(s), err
+}
+
+// UnmarshalJSON decodes a memory breakdown encoded by MarshalJSON.
+func UnmarshalJSON(data []byte) (*api.MemoryBreakdown, error) {
+	metrics := &api.Metrics{}
+	if err := jsonpb.Unmarshal(bytes.NewReader(data), metrics); err != nil {
+		return nil, err
+	}
+	return fromMetrics(metrics)
+}
+
+// fromMetrics returns the memory breakdown of the decoded metrics, with its
+// aliased regions computed if they weren't encoded.
+func fromMetrics(metrics *api.Metrics) (*api.MemoryBreakdown, error) {
+	mem := metrics.MemoryBreakdown
+	if mem == nil {
+		return nil, fmt.Errorf("The metrics do not have a memory breakdown")
+	}
+	if !mem.Aliasing {
+		ComputeAllocationAliasing(mem)
+	}
+	return mem, nil
+}
@@ -16,10 +16,10 @@ package resolve
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/google/gapid/core/log"
 	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/memory/breakdown"
 	"github.com/google/gapid/gapis/messages"
 	"github.com/google/gapid/gapis/service"
 	"github.com/google/gapid/gapis/service/path"
@@ -27,16 +27,26 @@ import (
 
 func Metrics(ctx context.Context, p *path.Metrics, r *path.ResolveConfig) (*api.Metrics, error) {
 	res := api.Metrics{}
-	if p.MemoryBreakdown {
-		breakdown, err := memoryBreakdown(ctx, p.Command, r)
+	if p.MemoryBreakdown || p.MemoryAliasing {
+		mem, err := memoryBreakdown(ctx, p.Command, r)
 		if err != nil {
 			return nil, log.Errf(ctx, err, "Failed to get memory breakdown")
 		}
-		res.MemoryBreakdown = breakdown
+		res.MemoryBreakdown = memoryMetrics(mem, p.MemoryAliasing)
 	}
 	return &res, nil
 }
 
+// memoryMetrics returns the memory breakdown mem, with the aliased regions of
+// its allocations if aliasing is set. mem is nil for the APIs without a memory
+// breakdown.
+func memoryMetrics(mem *api.MemoryBreakdown, aliasing bool) *api.MemoryBreakdown {
+	if aliasing && mem != nil {
+		breakdown.ComputeAllocationAliasing(mem)
+	}
+	return mem
+}
+
 func memoryBreakdown(ctx context.Context, c *path.Command, r *path.ResolveConfig) (*api.MemoryBreakdown, error) {
 	cmd, err := Cmd(ctx, c, r)
 	if err != nil {
@@ -54,6 +64,7 @@ func memoryBreakdown(ctx context.Context, c *path.Command, r *path.ResolveConfig
 	if ml, ok := a.(api.MemoryBreakdownProvider); ok {
 		return ml.MemoryBreakdown(state)
 	}
-	return nil, fmt.Errorf("Memory breakdown not supported for API %v", a.Name())
-
+	// The breakdown is left unset for the APIs that don't support it, so
+	// clients can tell them apart from a failure.
+	return nil, nil
 }
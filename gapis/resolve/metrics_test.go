@@ -0,0 +1,69 @@
+// Copyright (C) 2020 Google Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolve
+
+import (
+	"testing"
+
+	"github.com/google/gapid/core/assert"
+	"github.com/google/gapid/core/log"
+	"github.com/google/gapid/core/os/device/bind"
+	"github.com/google/gapid/gapis/api"
+	"github.com/google/gapid/gapis/capture"
+	"github.com/google/gapid/gapis/database"
+	"github.com/google/gapid/gapis/service/path"
+)
+
+func TestMetricsUnsupportedAPI(t *testing.T) {
+	ctx := log.Testing(t)
+	ctx = bind.PutRegistry(ctx, bind.NewRegistry())
+	ctx = database.Put(ctx, database.NewInMemory(ctx))
+	assert := assert.To(t)
+
+	// The test API has no memory breakdown.
+	p := newPathTest(ctx)
+	ctx = capture.Put(ctx, p)
+	for _, aliasing := range []bool{false, true} {
+		metrics, err := Metrics(ctx, &path.Metrics{Command: p.Command(1), MemoryBreakdown: true, MemoryAliasing: aliasing}, nil)
+		assert.For("err").ThatError(err).Succeeded()
+		assert.For("breakdown").That(metrics.MemoryBreakdown == nil).Equals(true)
+	}
+}
+
+func TestMemoryMetricsAliasing(t *testing.T) {
+	assert := assert.To(t)
+	breakdown := func() *api.MemoryBreakdown {
+		buffer := func(handle, offset uint64) *api.MemoryBinding {
+			return &api.MemoryBinding{Handle: handle, Offset: offset, Size: 32,
+				Type: &api.MemoryBinding_Buffer{Buffer: &api.NormalBinding{}}}
+		}
+		return &api.MemoryBreakdown{Allocations: []*api.MemoryAllocation{{
+			Handle:   1,
+			Size:     64,
+			Bindings: []*api.MemoryBinding{buffer(2, 0), buffer(3, 16)},
+		}}}
+	}
+
+	off := memoryMetrics(breakdown(), false)
+	assert.For("off aliasing").That(off.Aliasing).Equals(false)
+	assert.For("off aliases").That(len(off.Allocations[0].Aliases)).Equals(0)
+
+	on := memoryMetrics(breakdown(), true)
+	assert.For("on aliasing").That(on.Aliasing).Equals(true)
+	assert.For("on aliases").That(len(on.Allocations[0].Aliases)).Equals(1)
+	assert.For("on offset").That(on.Allocations[0].Aliases[0].Offset).Equals(uint64(16))
+
+	assert.For("unsupported").That(memoryMetrics(nil, true) == nil).Equals(true)
+}
@@ -665,6 +665,18 @@ func (s *grpcServer) PerfettoQuery(ctx xctx.Context, req *service.PerfettoQueryR
 	return &service.PerfettoQueryResponse{Res: &service.PerfettoQueryResponse_Result{Result: data}}, nil
 }
 
+func (s *grpcServer) MemoryAliasing(ctx xctx.Context, req *service.MemoryAliasingRequest) (*service.MemoryAliasingResponse, error) {
+	defer s.inRPC()()
+	mem, err := s.handler.MemoryAliasing(s.bindCtx(ctx), req.Command)
+	if err := service.NewError(err); err != nil {
+		return &service.MemoryAliasingResponse{Res: &service.MemoryAliasingResponse_Error{Error: err}}, nil
+	}
+	if mem == nil {
+		return &service.MemoryAliasingResponse{}, nil
+	}
+	return &service.MemoryAliasingResponse{Res: &service.MemoryAliasingResponse_Breakdown{Breakdown: mem}}, nil
+}
+
 func (s *grpcServer) ValidateDevice(ctx xctx.Context, req *service.ValidateDeviceRequest) (*service.ValidateDeviceResponse, error) {
 	err := s.handler.ValidateDevice(s.bindCtx(ctx), req.Device)
 	if err := service.NewError(err); err != nil {
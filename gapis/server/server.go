@@ -43,6 +43,7 @@ import (
 	"github.com/google/gapid/core/os/android/adb"
 	"github.com/google/gapid/core/os/device/bind"
 	"github.com/google/gapid/core/os/file"
+	"github.com/google/gapid/gapis/api"
 	"github.com/google/gapid/gapis/capture"
 	"github.com/google/gapid/gapis/config"
 	"github.com/google/gapid/gapis/messages"
@@ -950,6 +951,21 @@ func (s *server) PerfettoQuery(ctx context.Context, c *path.Capture, query strin
 	return res, nil
 }
 
+func (s *server) MemoryAliasing(ctx context.Context, c *path.Command) (*api.MemoryBreakdown, error) {
+	ctx = status.Start(ctx, "RPC MemoryAliasing")
+	defer status.Finish(ctx)
+	ctx = log.Enter(ctx, "MemoryAliasing")
+	res, err := resolve.Metrics(ctx, &path.Metrics{
+		Command:         c,
+		MemoryBreakdown: true,
+		MemoryAliasing:  true,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.MemoryBreakdown, nil
+}
+
 func (s *server) ValidateDevice(ctx context.Context, d *path.Device) error {
 	ctx = status.Start(ctx, "RPC ValidateDevice")
 	defer status.Finish(ctx)
@@ -177,6 +177,10 @@ type Service interface {
 	// Run a perfetto query
 	PerfettoQuery(ctx context.Context, c *path.Capture, query string) (*perfetto.QueryResult, error)
 
+	// Get the memory breakdown after a command, with the aliased regions of
+	// its allocations, or nil if the API doesn't support memory breakdowns.
+	MemoryAliasing(ctx context.Context, c *path.Command) (*api.MemoryBreakdown, error)
+
 	// Split out a new capture containing a subset of another capture's commands.
 	SplitCapture(ctx context.Context, rng *path.Commands) (*path.Capture, error)
 